@@ -0,0 +1,125 @@
+// Package agent is the attack agent that runs inside UE pods. The backend
+// pushes the static attack-agent binary (cmd/attack-agent) into a pod once,
+// starts it as a daemon listening on a loopback control address and drives it
+// with the same binary's start, stop and status commands through exec. The
+// control address can also be reached with a port-forward.
+//
+// The agent sends ICMP and UDP floods and crafted GTP-U traffic natively and
+// keeps live counters, so no interpreter, script or process hunting is needed.
+package agent
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// DefaultAddr is the loopback address the daemon listens on
+const DefaultAddr = "127.0.0.1:7878"
+
+// ExitUnreachable is the exit code of the client commands when no daemon
+// listens on the control address
+const ExitUnreachable = 3
+
+// Modes of a run
+const (
+	ModeICMP = "icmp" // ICMP echo request flood
+	ModeUDP  = "udp"  // UDP flood
+	ModeGTPU = "gtpu" // G-PDUs carrying inner ICMP echo requests, sent to the GTP-U port
+)
+
+// Reasons a run ended
+const (
+	ExitCompleted = "completed" // DurationSeconds elapsed
+	ExitStopped   = "stopped"   // Stopped on command
+	ExitFailed    = "failed"    // No sender could be started
+)
+
+// Limits on a run, matching the backend's attack parameter limits
+const (
+	maxThreads     = 64
+	maxPayloadSize = 65000
+)
+
+// Spec describes one run
+type Spec struct {
+	ID              string `json:"id"`                        // Chosen by the caller, e.g. the backend's job ID
+	Attack          string `json:"attack,omitempty"`          // Caller's name for what the run is part of, e.g. the backend's attack type
+	Mode            string `json:"mode"`                      // ModeICMP, ModeUDP or ModeGTPU
+	Target          string `json:"target"`                    // IPv4 address packets are sent to
+	Interface       string `json:"interface,omitempty"`       // Sends through this interface, e.g. uesimtun0; empty follows the routing table
+	DstPort         int    `json:"dstPort,omitempty"`         // UDP destination port, GTP-U runs always use the GTP-U port
+	PacketRate      int    `json:"packetRate,omitempty"`      // Packets per second over all senders, 0 sends as fast as possible
+	PayloadSize     int    `json:"payloadSize,omitempty"`     // Payload bytes per packet, of the inner packet for GTP-U runs
+	Threads         int    `json:"threads,omitempty"`         // Concurrent senders, each with its own socket
+	DurationSeconds int    `json:"durationSeconds,omitempty"` // Ends the run after this long, 0 runs until stopped
+	TEIDMin         uint32 `json:"teidMin,omitempty"`         // TEIDs cycled through by GTP-U runs
+	TEIDMax         uint32 `json:"teidMax,omitempty"`
+	InnerDst        string `json:"innerDst,omitempty"` // Destination of the inner packets of GTP-U runs
+	LogFile         string `json:"logFile,omitempty"`  // Receives the run's progress, empty logs to the daemon's stderr
+}
+
+// Counters are the totals of a run
+type Counters struct {
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+	Errors  uint64 `json:"errors"` // Failed sends
+}
+
+// Status describes the current or last run of a daemon
+type Status struct {
+	PID           int       `json:"pid"` // Of the daemon
+	Running       bool      `json:"running"`
+	Spec          *Spec     `json:"spec,omitempty"` // Nil before the first run
+	StartedAt     time.Time `json:"startedAt"`
+	EndedAt       time.Time `json:"endedAt"`
+	ExitReason    string    `json:"exitReason,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	Counters      Counters  `json:"counters"`
+	PacketsPerSec float64   `json:"packetsPerSec"` // Over the last second
+}
+
+// validate checks the spec and fills in the defaults
+func (s *Spec) validate() error {
+	if s.ID == "" {
+		return fmt.Errorf("id is required")
+	}
+	switch s.Mode {
+	case ModeICMP, ModeUDP, ModeGTPU:
+	default:
+		return fmt.Errorf("unknown mode %q", s.Mode)
+	}
+	if ip := net.ParseIP(s.Target); ip == nil || ip.To4() == nil {
+		return fmt.Errorf("target %q is not an IPv4 address", s.Target)
+	}
+	if s.Mode == ModeUDP && (s.DstPort < 1 || s.DstPort > 65535) {
+		return fmt.Errorf("dstPort must be between 1 and 65535")
+	}
+	if s.PacketRate < 0 {
+		return fmt.Errorf("packetRate must not be negative")
+	}
+	if s.PayloadSize < 0 || s.PayloadSize > maxPayloadSize {
+		return fmt.Errorf("payloadSize must be between 0 and %d", maxPayloadSize)
+	}
+	if s.Threads < 0 || s.Threads > maxThreads {
+		return fmt.Errorf("threads must be between 0 and %d", maxThreads)
+	}
+	if s.Threads == 0 {
+		s.Threads = 1
+	}
+	if s.DurationSeconds < 0 {
+		return fmt.Errorf("durationSeconds must not be negative")
+	}
+	if s.Mode == ModeGTPU {
+		if s.TEIDMax < s.TEIDMin {
+			return fmt.Errorf("teidMin %d is greater than teidMax %d", s.TEIDMin, s.TEIDMax)
+		}
+		if s.InnerDst == "" {
+			return fmt.Errorf("innerDst is required for %s runs", ModeGTPU)
+		}
+		if ip := net.ParseIP(s.InnerDst); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("innerDst %q is not an IPv4 address", s.InnerDst)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package agent
+
+import "syscall"
+
+// bindToDevice returns a socket control function that sends through the
+// interface regardless of the routing table, like ping -I
+func bindToDevice(iface string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var sockErr error
+		err := c.Control(func(fd uintptr) {
+			sockErr = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, iface)
+		})
+		if err != nil {
+			return err
+		}
+		return sockErr
+	}
+}
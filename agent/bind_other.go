@@ -0,0 +1,15 @@
+//go:build !linux
+
+package agent
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// bindToDevice is only supported on Linux, where the agent runs
+func bindToDevice(iface string) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		return fmt.Errorf("binding to interface %s is not supported on this platform", iface)
+	}
+}
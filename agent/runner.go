@@ -0,0 +1,206 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// progressInterval is how often a run logs its counters
+const progressInterval = 5 * time.Second
+
+// ErrRunning is returned when a run is started while another one is running
+var ErrRunning = errors.New("a run is already in progress")
+
+// Runner executes one run at a time and keeps the status of the last one
+type Runner struct {
+	mu  sync.Mutex
+	run *run
+}
+
+// run is one execution of a spec
+type run struct {
+	spec      Spec
+	cancel    context.CancelFunc
+	done      chan struct{} // Closed when every sender has returned
+	startedAt time.Time
+
+	packets, bytes, errors atomic.Uint64
+	rate                   atomic.Uint64 // math.Float64bits of the packets per second over the last second
+
+	// Set under Runner.mu when the run ends
+	endedAt    time.Time
+	exitReason string
+	err        string
+}
+
+// Start validates the spec and starts the run in the background
+func (r *Runner) Start(spec Spec) (Status, error) {
+	if err := spec.validate(); err != nil {
+		return Status{}, err
+	}
+
+	r.mu.Lock()
+	if r.run != nil && r.run.endedAt.IsZero() {
+		r.mu.Unlock()
+		return Status{}, ErrRunning
+	}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if spec.DurationSeconds > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(spec.DurationSeconds)*time.Second)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	current := &run{spec: spec, cancel: cancel, done: make(chan struct{}), startedAt: time.Now()}
+	r.run = current
+	r.mu.Unlock()
+
+	go r.execute(ctx, current)
+	return r.Status(), nil
+}
+
+// Stop ends the current run and waits for its senders to return. Stopping
+// when nothing runs only returns the status.
+func (r *Runner) Stop() Status {
+	r.mu.Lock()
+	current := r.run
+	if current != nil && current.endedAt.IsZero() && current.exitReason == "" {
+		current.exitReason = ExitStopped
+	}
+	r.mu.Unlock()
+
+	if current != nil {
+		current.cancel()
+		<-current.done
+	}
+	return r.Status()
+}
+
+// Status returns the state and counters of the current or last run
+func (r *Runner) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	status := Status{PID: os.Getpid()}
+	if r.run == nil {
+		return status
+	}
+	spec := r.run.spec
+	status.Spec = &spec
+	status.Running = r.run.endedAt.IsZero()
+	status.StartedAt = r.run.startedAt
+	status.EndedAt = r.run.endedAt
+	status.ExitReason = r.run.exitReason
+	status.Error = r.run.err
+	status.Counters = r.run.counters()
+	if status.Running {
+		status.PacketsPerSec = math.Float64frombits(r.run.rate.Load())
+	}
+	return status
+}
+
+func (r *run) counters() Counters {
+	return Counters{Packets: r.packets.Load(), Bytes: r.bytes.Load(), Errors: r.errors.Load()}
+}
+
+// execute runs the senders until the context ends and records the outcome
+func (r *Runner) execute(ctx context.Context, current *run) {
+	defer close(current.done)
+	spec := current.spec
+
+	logger, closeLog := runLogger(spec)
+	defer closeLog()
+	logger.Printf("run %s: %s flood against %s started with %d senders", spec.ID, spec.Mode, describe(spec), spec.Threads)
+
+	senders, err := openSenders(spec)
+	if err != nil {
+		current.cancel()
+		logger.Printf("run %s: failed: %v", spec.ID, err)
+		r.finish(current, ExitFailed, err.Error())
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, s := range senders {
+		wg.Add(1)
+		go func(s sender) {
+			defer wg.Done()
+			defer s.Close()
+			pace := newPacer(spec.PacketRate, len(senders))
+			for seq := 0; pace.wait(ctx); seq++ {
+				n, err := s.Send(seq)
+				if err != nil {
+					current.errors.Add(1)
+					continue
+				}
+				current.packets.Add(1)
+				current.bytes.Add(uint64(n))
+			}
+		}(s)
+	}
+
+	progress := time.NewTicker(progressInterval)
+	defer progress.Stop()
+	sample := time.NewTicker(time.Second)
+	defer sample.Stop()
+	last := current.packets.Load()
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case <-sample.C:
+			packets := current.packets.Load()
+			current.rate.Store(math.Float64bits(float64(packets - last)))
+			last = packets
+		case <-progress.C:
+			c := current.counters()
+			logger.Printf("run %s: sent %d packets (%d bytes) at %.0f pps, %d errors",
+				spec.ID, c.Packets, c.Bytes, math.Float64frombits(current.rate.Load()), c.Errors)
+		}
+	}
+	wg.Wait()
+
+	reason := ExitStopped
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		reason = ExitCompleted
+	}
+	r.finish(current, reason, "")
+	status := r.Status()
+	logger.Printf("run %s: ended (%s) after %d packets (%d bytes), %d errors",
+		spec.ID, status.ExitReason, status.Counters.Packets, status.Counters.Bytes, status.Counters.Errors)
+}
+
+// finish records the end of the run. A reason set by Stop is kept.
+func (r *Runner) finish(current *run, reason, err string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	current.endedAt = time.Now()
+	if current.exitReason == "" || reason == ExitFailed {
+		current.exitReason = reason
+	}
+	current.err = err
+}
+
+// runLogger logs to the spec's log file, or to stderr when it has none or
+// the file cannot be opened
+func runLogger(spec Spec) (*log.Logger, func()) {
+	var out io.Writer = os.Stderr
+	closeLog := func() {}
+	if spec.LogFile != "" {
+		f, err := os.OpenFile(spec.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "run %s: failed to open log file: %v\n", spec.ID, err)
+		} else {
+			out = f
+			closeLog = func() { f.Close() }
+		}
+	}
+	return log.New(out, "", log.LstdFlags), closeLog
+}
@@ -0,0 +1,194 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"k8s-status-api/gtpu"
+)
+
+// innerICMPID is the ICMP identifier of the inner packets of GTP-U runs,
+// matching the gtp_encapsulation script
+const innerICMPID = 0x5747
+
+// sender writes one packet per call on its own socket
+type sender interface {
+	Send(seq int) (int, error) // Returns the bytes written
+	Close() error
+}
+
+// openSenders opens one socket per thread of the spec
+func openSenders(spec Spec) ([]sender, error) {
+	var senders []sender
+	for i := 0; i < spec.Threads; i++ {
+		s, err := openSender(spec, i)
+		if err != nil {
+			for _, opened := range senders {
+				opened.Close()
+			}
+			return nil, err
+		}
+		senders = append(senders, s)
+	}
+	return senders, nil
+}
+
+// openSender opens the socket of the spec's mode, bound to its interface
+func openSender(spec Spec, index int) (sender, error) {
+	config := net.ListenConfig{}
+	if spec.Interface != "" {
+		config.Control = bindToDevice(spec.Interface)
+	}
+	target := net.ParseIP(spec.Target).To4()
+	payload := make([]byte, spec.PayloadSize)
+	for i := range payload {
+		payload[i] = 'A'
+	}
+
+	switch spec.Mode {
+	case ModeICMP:
+		// The kernel adds the IP header, a raw ICMP socket needs CAP_NET_RAW
+		conn, err := config.ListenPacket(context.Background(), "ip4:icmp", "0.0.0.0")
+		if err != nil {
+			return nil, fmt.Errorf("failed to open raw ICMP socket: %v", err)
+		}
+		id := uint16(os.Getpid() + index)
+		return &packetSender{conn: conn, addr: &net.IPAddr{IP: target}, build: func(seq int) []byte {
+			return gtpu.ICMPEcho(id, uint16(seq), payload)
+		}}, nil
+
+	case ModeUDP:
+		conn, err := config.ListenPacket(context.Background(), "udp4", ":0")
+		if err != nil {
+			return nil, fmt.Errorf("failed to open UDP socket: %v", err)
+		}
+		return &packetSender{conn: conn, addr: &net.UDPAddr{IP: target, Port: spec.DstPort}, build: func(int) []byte {
+			return payload
+		}}, nil
+
+	case ModeGTPU:
+		conn, err := config.ListenPacket(context.Background(), "udp4", ":0")
+		if err != nil {
+			return nil, fmt.Errorf("failed to open UDP socket: %v", err)
+		}
+		innerSrc, err := interfaceIPv4(spec.Interface)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		innerDst := net.ParseIP(spec.InnerDst)
+		teids := spec.TEIDMax - spec.TEIDMin + 1
+		return &packetSender{conn: conn, addr: &net.UDPAddr{IP: target, Port: gtpu.Port}, build: func(seq int) []byte {
+			teid := spec.TEIDMin
+			if teids != 0 { // Zero when the range covers every TEID
+				teid += uint32(seq) % teids
+			} else {
+				teid = uint32(seq)
+			}
+			inner, err := gtpu.IPv4ICMPEcho(innerSrc, innerDst, innerICMPID, uint16(seq), payload)
+			if err != nil {
+				return nil
+			}
+			packet, err := gtpu.NewGPDU(teid, inner).Marshal()
+			if err != nil {
+				return nil
+			}
+			return packet
+		}}, nil
+	}
+	return nil, fmt.Errorf("unknown mode %q", spec.Mode)
+}
+
+// packetSender writes the packets its build function returns to addr
+type packetSender struct {
+	conn  net.PacketConn
+	addr  net.Addr
+	build func(seq int) []byte
+}
+
+func (s *packetSender) Send(seq int) (int, error) {
+	packet := s.build(seq)
+	if packet == nil {
+		return 0, fmt.Errorf("failed to build packet %d", seq)
+	}
+	return s.conn.WriteTo(packet, s.addr)
+}
+
+func (s *packetSender) Close() error {
+	return s.conn.Close()
+}
+
+// interfaceIPv4 returns the first IPv4 address of the interface, the source
+// of the inner packets. Without an interface the inner source is unspecified.
+func interfaceIPv4(name string) (net.IP, error) {
+	if name == "" {
+		return net.IPv4zero, nil
+	}
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %v", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %v", name, err)
+	}
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+			return ipnet.IP.To4(), nil
+		}
+	}
+	return nil, fmt.Errorf("interface %s has no IPv4 address", name)
+}
+
+// pacer spreads a sender's share of the packet rate evenly over time
+type pacer struct {
+	interval time.Duration // Zero sends as fast as possible
+	next     time.Time
+}
+
+// newPacer returns the pacer of one of senders sharing rate packets per second
+func newPacer(rate, senders int) *pacer {
+	p := &pacer{next: time.Now()}
+	if rate > 0 {
+		p.interval = time.Duration(float64(time.Second) * float64(senders) / float64(rate))
+	}
+	return p
+}
+
+// wait blocks until the next packet is due and reports whether the run is
+// still going. Short waits are skipped and made up by the following packets,
+// since sleeping is far coarser than the interval at high rates.
+func (p *pacer) wait(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if p.interval == 0 {
+		return true
+	}
+	if delay := time.Until(p.next); delay > time.Millisecond {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+		}
+	}
+	p.next = p.next.Add(p.interval)
+	return true
+}
+
+// describe formats a sender's target for log messages
+func describe(spec Spec) string {
+	switch spec.Mode {
+	case ModeUDP:
+		return net.JoinHostPort(spec.Target, strconv.Itoa(spec.DstPort))
+	case ModeGTPU:
+		return net.JoinHostPort(spec.Target, strconv.Itoa(gtpu.Port))
+	}
+	return spec.Target
+}
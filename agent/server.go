@@ -0,0 +1,163 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// clientTimeout bounds a control request, stopping waits for the senders
+const clientTimeout = 30 * time.Second
+
+// errorResponse is the body of a failed control request
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handler serves the control API of the runner:
+//
+//	POST /start     starts a run from the Spec in the body
+//	POST /stop      stops the current run
+//	GET  /status    returns the current or last run
+//	POST /shutdown  stops the current run and calls shutdown
+//
+// Every request answers with a Status, or an errorResponse on failure.
+func Handler(runner *Runner, shutdown func()) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/start", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		var spec Spec
+		if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{fmt.Sprintf("invalid spec: %v", err)})
+			return
+		}
+		status, err := runner.Start(spec)
+		switch {
+		case errors.Is(err, ErrRunning):
+			writeJSON(w, http.StatusConflict, errorResponse{err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusBadRequest, errorResponse{err.Error()})
+		default:
+			writeJSON(w, http.StatusOK, status)
+		}
+	})
+	mux.HandleFunc("/stop", func(w http.ResponseWriter, r *http.Request) {
+		if allowMethod(w, r, http.MethodPost) {
+			writeJSON(w, http.StatusOK, runner.Stop())
+		}
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		if allowMethod(w, r, http.MethodGet) {
+			writeJSON(w, http.StatusOK, runner.Status())
+		}
+	})
+	mux.HandleFunc("/shutdown", func(w http.ResponseWriter, r *http.Request) {
+		if allowMethod(w, r, http.MethodPost) {
+			writeJSON(w, http.StatusOK, runner.Stop())
+			go shutdown()
+		}
+	})
+	return mux
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{fmt.Sprintf("use %s", method)})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+// UnreachableError is returned by the client when no daemon answers
+type UnreachableError struct {
+	Addr string
+	Err  error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("no agent listening on %s: %v", e.Addr, e.Err)
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// Client calls the control API of a daemon
+type Client struct {
+	Addr string // Defaults to DefaultAddr
+}
+
+// Start starts a run
+func (c *Client) Start(spec Spec) (Status, error) {
+	body, err := json.Marshal(spec)
+	if err != nil {
+		return Status{}, err
+	}
+	return c.call(http.MethodPost, "/start", body)
+}
+
+// Stop stops the current run
+func (c *Client) Stop() (Status, error) {
+	return c.call(http.MethodPost, "/stop", nil)
+}
+
+// Status returns the current or last run
+func (c *Client) Status() (Status, error) {
+	return c.call(http.MethodGet, "/status", nil)
+}
+
+// Shutdown stops the current run and the daemon
+func (c *Client) Shutdown() (Status, error) {
+	return c.call(http.MethodPost, "/shutdown", nil)
+}
+
+func (c *Client) call(method, path string, body []byte) (Status, error) {
+	addr := c.Addr
+	if addr == "" {
+		addr = DefaultAddr
+	}
+	req, err := http.NewRequest(method, "http://"+addr+path, bytes.NewReader(body))
+	if err != nil {
+		return Status{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := (&http.Client{Timeout: clientTimeout}).Do(req)
+	if err != nil {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return Status{}, &UnreachableError{Addr: addr, Err: opErr.Err}
+		}
+		return Status{}, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Status{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure errorResponse
+		if json.Unmarshal(data, &failure) == nil && failure.Error != "" {
+			return Status{}, errors.New(failure.Error)
+		}
+		return Status{}, fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	var status Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return Status{}, fmt.Errorf("invalid status: %v", err)
+	}
+	return status, nil
+}
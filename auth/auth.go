@@ -0,0 +1,225 @@
+// Package auth verifies the bearer tokens of API requests, either static API
+// keys or JWTs signed by a key of a local JWKS file, and maps the caller to a
+// role.
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Role is what a caller may do. Every role includes the ones below it.
+type Role int
+
+const (
+	RoleNone     Role = iota
+	RoleViewer        // Reads pods, statuses, jobs and logs
+	RoleOperator      // Runs traffic tests and stops attacks
+	RoleAttacker      // Launches attacks and scenarios
+	RoleAdmin         // Manages Helm releases and the trace collector configuration
+)
+
+var roleNames = map[Role]string{
+	RoleNone:     "none",
+	RoleViewer:   "viewer",
+	RoleOperator: "operator",
+	RoleAttacker: "attacker",
+	RoleAdmin:    "admin",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+// MarshalText encodes the role by name
+func (r Role) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// Allows reports whether the role grants the required one
+func (r Role) Allows(required Role) bool {
+	return r >= required
+}
+
+// ParseRole returns the role with the given name
+func ParseRole(name string) (Role, error) {
+	for role, roleName := range roleNames {
+		if role != RoleNone && strings.EqualFold(name, roleName) {
+			return role, nil
+		}
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", name)
+}
+
+// Methods a caller was authenticated with
+const (
+	MethodAPIKey    = "api-key"
+	MethodJWT       = "jwt"
+	MethodAnonymous = "anonymous" // Authentication is disabled
+)
+
+// Identity is an authenticated caller
+type Identity struct {
+	Subject string `json:"subject"` // API key name or JWT subject
+	Role    Role   `json:"role"`
+	Method  string `json:"method"`
+}
+
+// Anonymous is the caller of every request while authentication is
+// explicitly disabled
+var Anonymous = &Identity{Subject: "anonymous", Role: RoleAdmin, Method: MethodAnonymous}
+
+// minKeyLength is the shortest API key accepted, so keys cannot be guessed
+const minKeyLength = 16
+
+// defaultLeeway absorbs clock skew when checking exp and nbf
+const defaultLeeway = time.Minute
+
+// defaultRoleClaim is the JWT claim holding the caller's roles
+const defaultRoleClaim = "roles"
+
+// APIKey is a static bearer token granted one role
+type APIKey struct {
+	Name string
+	Key  string
+	Role Role
+}
+
+// Config selects the accepted credentials
+type Config struct {
+	// Disabled serves every request as Anonymous. It must be set explicitly,
+	// a Config without credentials is refused otherwise.
+	Disabled bool
+	APIKeys  []APIKey
+	JWKSFile string // Public keys JWTs are verified against, empty disables JWTs
+	Issuer   string // Required iss claim, if set
+	Audience string // Required aud entry, if set
+	// RoleClaim names the claim holding the caller's roles, with dots for
+	// nested claims such as "realm_access.roles". Defaults to "roles".
+	RoleClaim string
+	Leeway    time.Duration // Defaults to one minute
+}
+
+// apiKey is an API key with its token hashed, so tokens are compared in
+// constant time whatever their length
+type apiKey struct {
+	name   string
+	role   Role
+	digest [sha256.Size]byte
+}
+
+// Authenticator maps bearer tokens to identities
+type Authenticator struct {
+	disabled  bool
+	apiKeys   []apiKey
+	keys      *KeySet
+	issuer    string
+	audience  string
+	roleClaim []string
+	leeway    time.Duration
+	now       func() time.Time
+}
+
+// New builds the authenticator for cfg, loading its JWKS file. It fails
+// when cfg configures no credentials without disabling authentication.
+func New(cfg Config) (*Authenticator, error) {
+	if cfg.Disabled {
+		if len(cfg.APIKeys) > 0 || cfg.JWKSFile != "" {
+			return nil, errors.New("authentication is disabled but API keys or a JWKS file are configured")
+		}
+		return &Authenticator{disabled: true}, nil
+	}
+	if len(cfg.APIKeys) == 0 && cfg.JWKSFile == "" {
+		return nil, errors.New("no API keys or JWKS file configured; disable authentication explicitly to serve requests unauthenticated")
+	}
+
+	a := &Authenticator{
+		issuer:    cfg.Issuer,
+		audience:  cfg.Audience,
+		roleClaim: strings.Split(defaultRoleClaim, "."),
+		leeway:    defaultLeeway,
+		now:       time.Now,
+	}
+	if cfg.RoleClaim != "" {
+		a.roleClaim = strings.Split(cfg.RoleClaim, ".")
+	}
+	if cfg.Leeway > 0 {
+		a.leeway = cfg.Leeway
+	}
+
+	names := make(map[string]bool)
+	digests := make(map[[sha256.Size]byte]bool)
+	for _, key := range cfg.APIKeys {
+		if key.Name == "" {
+			return nil, errors.New("API key without a name")
+		}
+		if names[key.Name] {
+			return nil, fmt.Errorf("API key %q is defined twice", key.Name)
+		}
+		if len(key.Key) < minKeyLength {
+			return nil, fmt.Errorf("API key %q is shorter than %d characters", key.Name, minKeyLength)
+		}
+		if key.Role == RoleNone {
+			return nil, fmt.Errorf("API key %q has no role", key.Name)
+		}
+		digest := sha256.Sum256([]byte(key.Key))
+		if digests[digest] {
+			return nil, fmt.Errorf("API key %q reuses the key of another", key.Name)
+		}
+		names[key.Name], digests[digest] = true, true
+		a.apiKeys = append(a.apiKeys, apiKey{name: key.Name, role: key.Role, digest: digest})
+	}
+
+	if cfg.JWKSFile != "" {
+		keys, err := LoadKeySet(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = keys
+	}
+	return a, nil
+}
+
+// Disabled reports whether authentication was explicitly disabled, so every
+// request is served as Anonymous
+func (a *Authenticator) Disabled() bool {
+	return a != nil && a.disabled
+}
+
+// Authenticate returns the identity a bearer token stands for. A nil
+// Authenticator accepts no token.
+func (a *Authenticator) Authenticate(token string) (*Identity, error) {
+	if a == nil {
+		return nil, errors.New("authentication is not configured")
+	}
+	if token == "" {
+		return nil, errors.New("no token")
+	}
+	if identity := a.lookupAPIKey(token); identity != nil {
+		return identity, nil
+	}
+	if a.keys != nil && strings.Count(token, ".") == 2 {
+		return a.verifyJWT(token)
+	}
+	return nil, errors.New("unknown API key")
+}
+
+// lookupAPIKey returns the identity of a matching API key. Every key is
+// compared so that the time taken does not reveal which one matched.
+func (a *Authenticator) lookupAPIKey(token string) *Identity {
+	digest := sha256.Sum256([]byte(token))
+	var match *Identity
+	for _, key := range a.apiKeys {
+		if subtle.ConstantTimeCompare(digest[:], key.digest[:]) == 1 {
+			match = &Identity{Subject: key.name, Role: key.role, Method: MethodAPIKey}
+		}
+	}
+	return match
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	key := APIKey{Name: "dashboard", Key: "0123456789abcdef", Role: RoleViewer}
+	tests := []struct {
+		name     string
+		cfg      Config
+		disabled bool
+		wantErr  string
+	}{
+		{"no credentials", Config{}, false, "no API keys or JWKS file"},
+		{"explicitly disabled", Config{Disabled: true}, true, ""},
+		{"disabled with credentials", Config{Disabled: true, APIKeys: []APIKey{key}}, false, "disabled but"},
+		{"API key", Config{APIKeys: []APIKey{key}}, false, ""},
+		{"short API key", Config{APIKeys: []APIKey{{Name: "short", Key: "tooshort", Role: RoleViewer}}}, false, "shorter than"},
+		{"API key without role", Config{APIKeys: []APIKey{{Name: "none", Key: key.Key}}}, false, "no role"},
+		{"reused API key", Config{APIKeys: []APIKey{key, {Name: "copy", Key: key.Key, Role: RoleAdmin}}}, false, "reuses"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.Disabled() != tt.disabled {
+				t.Errorf("Disabled = %v, want %v", a.Disabled(), tt.disabled)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	a, err := New(Config{APIKeys: []APIKey{
+		{Name: "dashboard", Key: "0123456789abcdef", Role: RoleViewer},
+		{Name: "ci", Key: "fedcba9876543210", Role: RoleAttacker},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity, err := a.Authenticate("fedcba9876543210")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "ci" || identity.Role != RoleAttacker || identity.Method != MethodAPIKey {
+		t.Errorf("identity = %+v, want ci with role attacker", identity)
+	}
+	for _, token := range []string{"", "0123456789abcde", "a.b.c"} {
+		if _, err := a.Authenticate(token); err == nil {
+			t.Errorf("token %q was accepted", token)
+		}
+	}
+
+	var unset *Authenticator
+	if _, err := unset.Authenticate("0123456789abcdef"); err == nil {
+		t.Error("a nil authenticator accepted a token")
+	}
+}
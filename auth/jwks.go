@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// jsonWebKey is a public key of a JWKS document (RFC 7517). Only RSA and EC
+// signing keys are used.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// verificationKey is a parsed signing key
+type verificationKey struct {
+	kid string
+	alg string // Algorithm the key is restricted to, if any
+	key crypto.PublicKey
+}
+
+// KeySet holds the public keys JWTs are verified against
+type KeySet struct {
+	keys []verificationKey
+}
+
+// LoadKeySet reads a JWKS file
+func LoadKeySet(path string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file %s: %v", path, err)
+	}
+	keys, err := ParseKeySet(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS file %s: %v", path, err)
+	}
+	return keys, nil
+}
+
+// ParseKeySet parses a JWKS document. Keys meant for encryption and key
+// types other than RSA and EC are skipped.
+func ParseKeySet(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	set := &KeySet{}
+	for i, jwk := range doc.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = rsaKey(jwk)
+		case "EC":
+			key, err = ecKey(jwk)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %d (%q): %v", i, jwk.Kid, err)
+		}
+		set.keys = append(set.keys, verificationKey{kid: jwk.Kid, alg: jwk.Alg, key: key})
+	}
+	if len(set.keys) == 0 {
+		return nil, errors.New("no RSA or EC signing keys")
+	}
+	return set, nil
+}
+
+// candidates returns the keys that may have signed a token with the given
+// key ID and algorithm
+func (s *KeySet) candidates(kid, alg string) []verificationKey {
+	var keys []verificationKey
+	for _, key := range s.keys {
+		if kid != "" && key.kid != kid {
+			continue
+		}
+		if key.alg != "" && key.alg != alg {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func rsaKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(jwk.N)
+	if err != nil {
+		return nil, fmt.Errorf("invalid modulus: %v", err)
+	}
+	e, err := decodeBigInt(jwk.E)
+	if err != nil || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid exponent")
+	}
+	if n.BitLen() < 2048 {
+		return nil, fmt.Errorf("modulus of %d bits is too short", n.BitLen())
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func ecKey(jwk jsonWebKey) (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+	}
+	x, err := decodeBigInt(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("invalid x: %v", err)
+	}
+	y, err := decodeBigInt(jwk.Y)
+	if err != nil {
+		return nil, fmt.Errorf("invalid y: %v", err)
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on the curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// decodeBigInt decodes an unpadded base64url big-endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
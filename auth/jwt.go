@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	_ "crypto/sha256" // Registers SHA-256 for crypto.Hash
+	_ "crypto/sha512" // Registers SHA-384 and SHA-512 for crypto.Hash
+)
+
+// signingAlgorithm is a JWS algorithm accepted for JWTs. Unsigned tokens and
+// the HMAC algorithms, whose keys would have to be shared, are refused.
+type signingAlgorithm struct {
+	hash    crypto.Hash
+	kind    string // "RSA", "RSA-PSS" or "EC"
+	keySize int    // Size in bytes of r and s for EC signatures
+}
+
+var signingAlgorithms = map[string]signingAlgorithm{
+	"RS256": {crypto.SHA256, "RSA", 0},
+	"RS384": {crypto.SHA384, "RSA", 0},
+	"RS512": {crypto.SHA512, "RSA", 0},
+	"PS256": {crypto.SHA256, "RSA-PSS", 0},
+	"PS384": {crypto.SHA384, "RSA-PSS", 0},
+	"PS512": {crypto.SHA512, "RSA-PSS", 0},
+	"ES256": {crypto.SHA256, "EC", 32},
+	"ES384": {crypto.SHA384, "EC", 48},
+	"ES512": {crypto.SHA512, "EC", 66},
+}
+
+// jwtHeader is the protected header of a JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// verifyJWT checks a compact JWT's signature against the key set and its
+// time, issuer and audience claims, and returns the identity it carries
+func (a *Authenticator) verifyJWT(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid JWT header: %v", err)
+	}
+	alg, ok := signingAlgorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("JWT algorithm %q is not accepted", header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT signature: %v", err)
+	}
+
+	hasher := alg.hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	digest := hasher.Sum(nil)
+	verified := false
+	for _, key := range a.keys.candidates(header.Kid, header.Alg) {
+		if verifySignature(alg, key.key, digest, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("JWT signature does not match any key")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid JWT claims: %v", err)
+	}
+	if err := a.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	role := a.claimedRole(claims)
+	if role == RoleNone {
+		return nil, fmt.Errorf("JWT grants no known role in claim %q", strings.Join(a.roleClaim, "."))
+	}
+	subject, _ := claims["sub"].(string)
+	return &Identity{Subject: subject, Role: role, Method: MethodJWT}, nil
+}
+
+// verifySignature checks a signature made with the algorithm by the key
+func verifySignature(alg signingAlgorithm, key crypto.PublicKey, digest, signature []byte) bool {
+	switch alg.kind {
+	case "RSA":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(rsaKey, alg.hash, digest, signature) == nil
+	case "RSA-PSS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPSS(rsaKey, alg.hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}) == nil
+	case "EC":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || (ecKey.Curve.Params().BitSize+7)/8 != alg.keySize || len(signature) != 2*alg.keySize {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:alg.keySize])
+		s := new(big.Int).SetBytes(signature[alg.keySize:])
+		return ecdsa.Verify(ecKey, digest, r, s)
+	}
+	return false
+}
+
+// checkClaims checks the token's validity period, issuer and audience. A
+// token without an expiry is refused.
+func (a *Authenticator) checkClaims(claims map[string]interface{}) error {
+	now := a.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("JWT has no exp claim")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(a.leeway)) {
+		return errors.New("JWT has expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("JWT is not valid yet")
+	}
+	if a.issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != a.issuer {
+			return fmt.Errorf("JWT issuer %q is not accepted", iss)
+		}
+	}
+	if a.audience != "" && !containsString(claims["aud"], a.audience) {
+		return fmt.Errorf("JWT is not meant for audience %q", a.audience)
+	}
+	return nil
+}
+
+// claimedRole returns the highest known role listed in the role claim, which
+// holds a single role or a list of them
+func (a *Authenticator) claimedRole(claims map[string]interface{}) Role {
+	var value interface{} = claims
+	for _, name := range a.roleClaim {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return RoleNone
+		}
+		value = object[name]
+	}
+
+	var names []string
+	switch v := value.(type) {
+	case string:
+		names = strings.Fields(v)
+	case []interface{}:
+		for _, item := range v {
+			if name, ok := item.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	best := RoleNone
+	for _, name := range names {
+		if role, err := ParseRole(name); err == nil && role > best {
+			best = role
+		}
+	}
+	return best
+}
+
+// containsString reports whether a claim is the value or a list holding it
+func containsString(claim interface{}, value string) bool {
+	switch v := claim.(type) {
+	case string:
+		return v == value
+	case []interface{}:
+		for _, item := range v {
+			if item == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
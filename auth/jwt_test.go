@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// now is the fixed clock the test tokens are checked at
+var now = time.Unix(1_800_000_000, 0)
+
+// testKeys are generated once, RSA key generation being slow
+var (
+	rsaTestKey   = mustRSAKey(2048)
+	ecTestKey    = mustECKey()
+	shortTestKey = mustRSAKey(1024)
+)
+
+func mustRSAKey(bits int) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func mustECKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func b64(data []byte) string { return base64.RawURLEncoding.EncodeToString(data) }
+
+func rsaJWK(kid, alg string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA", "kid": kid, "alg": alg, "use": "sig",
+		"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid, alg string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC", "kid": kid, "alg": alg, "crv": "P-256",
+		"x": b64(key.X.FillBytes(make([]byte, 32))), "y": b64(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func jwksDocument(t *testing.T, keys ...map[string]string) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// newTestAuthenticator builds an authenticator trusting the RSA key as "rsa"
+// and the EC key as "ec", restricted to ES256
+func newTestAuthenticator(t *testing.T, cfg Config) *Authenticator {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	doc := jwksDocument(t, rsaJWK("rsa", "", &rsaTestKey.PublicKey), ecJWK("ec", "ES256", &ecTestKey.PublicKey))
+	if err := os.WriteFile(path, doc, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg.JWKSFile = path
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.now = func() time.Time { return now }
+	return a
+}
+
+// signToken builds a compact JWT. The key is an *rsa.PrivateKey, an
+// *ecdsa.PrivateKey, an HMAC secret or nil for an unsigned token.
+func signToken(t *testing.T, header map[string]string, claims map[string]interface{}, key interface{}) string {
+	t.Helper()
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	input := b64(h) + "." + b64(c)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	var err error
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if header["alg"] == "PS256" {
+			signature, err = rsa.SignPSS(rand.Reader, k, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + b64(signature)
+}
+
+// validClaims returns claims every test authenticator accepts
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":   "alice",
+		"iss":   "https://idp.example",
+		"aud":   []interface{}{"other", "k8s-status-api"},
+		"exp":   now.Add(time.Hour).Unix(),
+		"nbf":   now.Add(-time.Hour).Unix(),
+		"roles": []interface{}{"viewer", "operator"},
+	}
+}
+
+func withClaim(name string, value interface{}) map[string]interface{} {
+	claims := validClaims()
+	if value == nil {
+		delete(claims, name)
+	} else {
+		claims[name] = value
+	}
+	return claims
+}
+
+func TestVerifyJWT(t *testing.T) {
+	a := newTestAuthenticator(t, Config{Issuer: "https://idp.example", Audience: "k8s-status-api"})
+	rs256 := map[string]string{"alg": "RS256", "kid": "rsa", "typ": "JWT"}
+	es256 := map[string]string{"alg": "ES256", "kid": "ec"}
+
+	tests := []struct {
+		name    string
+		token   string
+		role    Role
+		wantErr string
+	}{
+		{"valid RS256", signToken(t, rs256, validClaims(), rsaTestKey), RoleOperator, ""},
+		{"valid PS256", signToken(t, map[string]string{"alg": "PS256", "kid": "rsa"}, validClaims(), rsaTestKey), RoleOperator, ""},
+		{"valid ES256", signToken(t, es256, validClaims(), ecTestKey), RoleOperator, ""},
+		{"valid without kid", signToken(t, map[string]string{"alg": "RS256"}, validClaims(), rsaTestKey), RoleOperator, ""},
+		{"single audience", signToken(t, rs256, withClaim("aud", "k8s-status-api"), rsaTestKey), RoleOperator, ""},
+		{"expired within leeway", signToken(t, rs256, withClaim("exp", now.Add(-30*time.Second).Unix()), rsaTestKey), RoleOperator, ""},
+
+		{"unknown kid", signToken(t, map[string]string{"alg": "RS256", "kid": "other"}, validClaims(), rsaTestKey), 0, "does not match any key"},
+		{"kid of another key", signToken(t, map[string]string{"alg": "RS256", "kid": "ec"}, validClaims(), rsaTestKey), 0, "does not match any key"},
+		{"alg the key is not restricted to", signToken(t, map[string]string{"alg": "ES384", "kid": "ec"}, validClaims(), ecTestKey), 0, "does not match any key"},
+		{"alg none", signToken(t, map[string]string{"alg": "none"}, validClaims(), nil), 0, `algorithm "none" is not accepted`},
+		{"HS256 with the public key as secret", signToken(t, map[string]string{"alg": "HS256", "kid": "rsa"}, validClaims(), rsaTestKey.PublicKey.N.Bytes()), 0, `algorithm "HS256" is not accepted`},
+		{"tampered claims", tamper(signToken(t, rs256, validClaims(), rsaTestKey)), 0, "does not match any key"},
+
+		{"expired", signToken(t, rs256, withClaim("exp", now.Add(-time.Hour).Unix()), rsaTestKey), 0, "expired"},
+		{"no exp", signToken(t, rs256, withClaim("exp", nil), rsaTestKey), 0, "no exp claim"},
+		{"not valid yet", signToken(t, rs256, withClaim("nbf", now.Add(time.Hour).Unix()), rsaTestKey), 0, "not valid yet"},
+		{"issuer mismatch", signToken(t, rs256, withClaim("iss", "https://evil.example"), rsaTestKey), 0, "issuer"},
+		{"audience mismatch", signToken(t, rs256, withClaim("aud", []interface{}{"other"}), rsaTestKey), 0, "audience"},
+		{"no known role", signToken(t, rs256, withClaim("roles", []interface{}{"root"}), rsaTestKey), 0, "no known role"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := a.Authenticate(tt.token)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if identity.Role != tt.role || identity.Subject != "alice" || identity.Method != MethodJWT {
+				t.Errorf("identity = %+v, want alice with role %s", identity, tt.role)
+			}
+		})
+	}
+}
+
+// tamper swaps the token's claims for a copy granting admin
+func tamper(token string) string {
+	parts := strings.Split(token, ".")
+	claims := validClaims()
+	claims["roles"] = "admin"
+	c, _ := json.Marshal(claims)
+	return parts[0] + "." + b64(c) + "." + parts[2]
+}
+
+func TestClaimedRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		roleClaim string
+		claims    map[string]interface{}
+		want      Role
+	}{
+		{"list", "", map[string]interface{}{"roles": []interface{}{"viewer", "attacker"}}, RoleAttacker},
+		{"space-separated string", "", map[string]interface{}{"roles": "operator viewer"}, RoleOperator},
+		{"unknown names are ignored", "", map[string]interface{}{"roles": []interface{}{"root", 7, "Viewer"}}, RoleViewer},
+		{"nested claim", "realm_access.roles", map[string]interface{}{
+			"realm_access": map[string]interface{}{"roles": []interface{}{"offline_access", "admin"}},
+		}, RoleAdmin},
+		{"nested claim missing", "realm_access.roles", map[string]interface{}{"roles": []interface{}{"admin"}}, RoleNone},
+		{"nested claim not an object", "realm_access.roles", map[string]interface{}{"realm_access": "admin"}, RoleNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuthenticator(t, Config{RoleClaim: tt.roleClaim})
+			if got := a.claimedRole(tt.claims); got != tt.want {
+				t.Errorf("claimedRole = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// A nested role claim end to end
+	a := newTestAuthenticator(t, Config{RoleClaim: "realm_access.roles"})
+	claims := withClaim("roles", nil)
+	claims["realm_access"] = map[string]interface{}{"roles": []interface{}{"attacker"}}
+	identity, err := a.Authenticate(signToken(t, map[string]string{"alg": "ES256", "kid": "ec"}, claims, ecTestKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Role != RoleAttacker {
+		t.Errorf("role = %s, want attacker", identity.Role)
+	}
+}
+
+func TestParseKeySet(t *testing.T) {
+	offCurve := ecJWK("bad", "", &ecTestKey.PublicKey)
+	offCurve["y"] = b64([]byte{1})
+	encryption := rsaJWK("enc", "", &rsaTestKey.PublicKey)
+	encryption["use"] = "enc"
+
+	tests := []struct {
+		name    string
+		doc     []byte
+		keys    int
+		wantErr string
+	}{
+		{"RSA and EC keys", jwksDocument(t, rsaJWK("rsa", "RS256", &rsaTestKey.PublicKey), ecJWK("ec", "", &ecTestKey.PublicKey)), 2, ""},
+		{"encryption and unknown keys are skipped", jwksDocument(t, encryption, map[string]string{"kty": "oct", "k": "c2VjcmV0"}, ecJWK("ec", "", &ecTestKey.PublicKey)), 1, ""},
+		{"too short RSA modulus", jwksDocument(t, rsaJWK("short", "", &shortTestKey.PublicKey)), 0, "modulus of 1024 bits is too short"},
+		{"point off the curve", jwksDocument(t, offCurve), 0, "not on the curve"},
+		{"unsupported curve", jwksDocument(t, map[string]string{"kty": "EC", "crv": "P-192", "x": "AQ", "y": "AQ"}), 0, "unsupported curve"},
+		{"no signing keys", jwksDocument(t, encryption), 0, "no RSA or EC signing keys"},
+		{"invalid JSON", []byte("{"), 0, "unexpected end"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := ParseKeySet(tt.doc)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(set.keys) != tt.keys {
+				t.Errorf("got %d keys, want %d", len(set.keys), tt.keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+// Command attack-agent is the agent the backend pushes into UE pods. Build it
+// statically so it runs in any pod image:
+//
+//	CGO_ENABLED=0 GOOS=linux go build -o bin/attack-agent ./cmd/attack-agent
+//
+// "attack-agent serve" runs the daemon. The start, stop, status and shutdown
+// commands call a running daemon and print its status as JSON; start reads
+// the run's spec as JSON from stdin.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"k8s-status-api/agent"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s serve|start|stop|status|shutdown [-addr %s]\n", os.Args[0], agent.DefaultAddr)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command := os.Args[1]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	addr := flags.String("addr", agent.DefaultAddr, "control address of the daemon")
+	flags.Parse(os.Args[2:])
+
+	if command == "serve" {
+		serve(*addr)
+		return
+	}
+
+	client := &agent.Client{Addr: *addr}
+	var status agent.Status
+	var err error
+	switch command {
+	case "start":
+		var spec agent.Spec
+		if err := json.NewDecoder(os.Stdin).Decode(&spec); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid spec on stdin: %v\n", err)
+			os.Exit(2)
+		}
+		status, err = client.Start(spec)
+	case "stop":
+		status, err = client.Stop()
+	case "status":
+		status, err = client.Status()
+	case "shutdown":
+		status, err = client.Shutdown()
+	default:
+		usage()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		var unreachable *agent.UnreachableError
+		if errors.As(err, &unreachable) {
+			os.Exit(agent.ExitUnreachable)
+		}
+		os.Exit(1)
+	}
+	json.NewEncoder(os.Stdout).Encode(status)
+}
+
+// serve runs the daemon until it is shut down or signalled, stopping the
+// current run on the way out
+func serve(addr string) {
+	runner := &agent.Runner{}
+	server := &http.Server{Addr: addr}
+
+	shutdown := func() {
+		runner.Stop()
+		server.Shutdown(context.Background())
+	}
+	server.Handler = agent.Handler(runner, shutdown)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		shutdown()
+	}()
+
+	log.Printf("attack agent %d listening on %s", os.Getpid(), addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("failed to serve: %v", err)
+	}
+	log.Printf("attack agent %d stopped", os.Getpid())
+}
@@ -0,0 +1,197 @@
+// Package config loads the backend settings file.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultPath is the settings file read when BACKEND_CONFIG is not set
+const DefaultPath = "config.json"
+
+// DefaultJobStoreFile is where attack and traffic jobs are persisted
+const DefaultJobStoreFile = "jobs.json"
+
+// DefaultDatasetDir is where labelled dataset exports are written
+const DefaultDatasetDir = "datasets"
+
+// DefaultGroundTruthFile is where attack ground-truth records are appended
+const DefaultGroundTruthFile = "ground_truth.jsonl"
+
+// DefaultTargetAllowlist are the testbed's pod and UE networks
+var DefaultTargetAllowlist = []string{"10.42.0.0/16", "10.45.0.0/16"}
+
+// ExampleAPIKey is the placeholder key of config.example.json. It is public,
+// so Load refuses it.
+const ExampleAPIKey = "change-me-to-a-long-random-key"
+
+// Config holds settings that differ between testbed installations
+type Config struct {
+	// ScriptsDir optionally overrides the embedded attack and traffic scripts
+	ScriptsDir string `json:"scriptsDir"`
+	// GroundTruthFile is the JSON lines file recording when each attack ran
+	GroundTruthFile string `json:"groundTruthFile"`
+	// DatasetDir receives the labelled CSV and manifest of each dataset export
+	DatasetDir string `json:"datasetDir"`
+	// JobStoreFile persists attack and traffic jobs across restarts
+	JobStoreFile string `json:"jobStoreFile"`
+	// ToolsDir holds static binaries (bin/) and Python wheels (wheels/)
+	// pushed into pods instead of installing them from the internet
+	ToolsDir string `json:"toolsDir"`
+	// OfflineTools forbids apt and pip, so only ToolsDir is used
+	OfflineTools bool `json:"offlineTools"`
+	// EphemeralImage is the tools image for attacks run in an ephemeral
+	// container. Empty disables ephemeral runs.
+	EphemeralImage string `json:"ephemeralImage"`
+	// AgentBinary is the static attack-agent build pushed into pods for the
+	// agent attacks. Empty disables them.
+	AgentBinary string `json:"agentBinary"`
+	// TargetAllowlist holds the CIDRs attack targets must fall in. An
+	// empty list refuses every target; 0.0.0.0/0 and ::/0 allow any.
+	TargetAllowlist []string `json:"targetAllowlist"`
+	// Auth selects the credentials API requests must carry
+	Auth AuthConfig `json:"auth"`
+	// CORSOrigins are the origins browsers may call the API from
+	CORSOrigins []string `json:"corsOrigins"`
+}
+
+// AuthConfig configures API authentication. The backend refuses to start
+// without API keys or a JWKS file unless Disabled is set.
+type AuthConfig struct {
+	// Disabled serves every request unauthenticated with the admin role
+	Disabled bool `json:"disabled"`
+	// APIKeys are static bearer tokens, each granted one role
+	APIKeys []APIKey `json:"apiKeys"`
+	// JWKSFile holds the public keys JWTs are verified against, e.g. those
+	// of the OIDC provider. Empty disables JWTs.
+	JWKSFile string `json:"jwksFile"`
+	// Issuer and Audience, when set, must match the iss and aud claims
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+	// RoleClaim names the JWT claim holding the caller's roles, with dots
+	// for nested claims. Defaults to "roles".
+	RoleClaim string `json:"roleClaim"`
+}
+
+// APIKey is a static bearer token and the role it grants: viewer,
+// operator, attacker or admin
+type APIKey struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Role string `json:"role"`
+}
+
+// Path returns the settings file location from BACKEND_CONFIG or DefaultPath
+func Path() string {
+	if path := os.Getenv("BACKEND_CONFIG"); path != "" {
+		return path
+	}
+	return DefaultPath
+}
+
+// Load reads the settings file at path. A missing file yields the defaults,
+// which still need credentials or AUTH_DISABLED for the backend to start.
+// Environment variables take precedence over the file.
+func Load(path string) (*Config, error) {
+	cfg := &Config{
+		GroundTruthFile: DefaultGroundTruthFile,
+		DatasetDir:      DefaultDatasetDir,
+		JobStoreFile:    DefaultJobStoreFile,
+		TargetAllowlist: append([]string(nil), DefaultTargetAllowlist...), // Copied, so the file cannot overwrite the default
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config file %s: %v", path, err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+		}
+	}
+
+	if dir := os.Getenv("SCRIPTS_DIR"); dir != "" {
+		cfg.ScriptsDir = dir
+	}
+	if file := os.Getenv("GROUND_TRUTH_FILE"); file != "" {
+		cfg.GroundTruthFile = file
+	}
+	if dir := os.Getenv("DATASET_DIR"); dir != "" {
+		cfg.DatasetDir = dir
+	}
+	if file := os.Getenv("JOB_STORE_FILE"); file != "" {
+		cfg.JobStoreFile = file
+	}
+	if dir := os.Getenv("TOOLS_DIR"); dir != "" {
+		cfg.ToolsDir = dir
+	}
+	if offline := os.Getenv("OFFLINE_TOOLS"); offline != "" {
+		value, err := strconv.ParseBool(offline)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OFFLINE_TOOLS value %q: %v", offline, err)
+		}
+		cfg.OfflineTools = value
+	}
+	if image := os.Getenv("EPHEMERAL_IMAGE"); image != "" {
+		cfg.EphemeralImage = image
+	}
+	if binary := os.Getenv("ATTACK_AGENT_BINARY"); binary != "" {
+		cfg.AgentBinary = binary
+	}
+	if allowlist, ok := os.LookupEnv("TARGET_ALLOWLIST"); ok {
+		cfg.TargetAllowlist = splitList(allowlist)
+	}
+	if disabled := os.Getenv("AUTH_DISABLED"); disabled != "" {
+		value, err := strconv.ParseBool(disabled)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_DISABLED value %q: %v", disabled, err)
+		}
+		cfg.Auth.Disabled = value
+	}
+	if keys, ok := os.LookupEnv("API_KEYS"); ok {
+		apiKeys, err := parseAPIKeys(keys)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Auth.APIKeys = apiKeys
+	}
+	if file := os.Getenv("AUTH_JWKS_FILE"); file != "" {
+		cfg.Auth.JWKSFile = file
+	}
+	if origins, ok := os.LookupEnv("CORS_ORIGINS"); ok {
+		cfg.CORSOrigins = splitList(origins)
+	}
+	for _, key := range cfg.Auth.APIKeys {
+		if key.Key == ExampleAPIKey {
+			return nil, fmt.Errorf("API key %q still uses the key of config.example.json, replace it with a random one", key.Name)
+		}
+	}
+	return cfg, nil
+}
+
+// splitList splits a comma-separated environment value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseAPIKeys parses API_KEYS, a comma-separated list of name:role:key
+func parseAPIKeys(value string) ([]APIKey, error) {
+	var keys []APIKey
+	for _, entry := range splitList(value) {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid API_KEYS entry, expected name:role:key")
+		}
+		keys = append(keys, APIKey{Name: parts[0], Role: parts[1], Key: parts[2]})
+	}
+	return keys, nil
+}
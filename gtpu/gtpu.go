@@ -0,0 +1,184 @@
+// Package gtpu builds GTP-U (version 1, TS 29.281) packets and the inner
+// IPv4, UDP and ICMP packets they carry. Every header field can be set
+// explicitly, including values that make the packet malformed, so the same
+// code crafts both valid traffic and attack traffic.
+package gtpu
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Port is the UDP port GTP-U is carried on
+const Port = 2152
+
+// Version1 is the only GTP-U version; any other value is malformed
+const Version1 = 1
+
+// headerLen is the length of the mandatory GTP-U header
+const headerLen = 8
+
+// Message types
+const (
+	MsgEchoRequest        uint8 = 1
+	MsgEchoResponse       uint8 = 2
+	MsgErrorIndication    uint8 = 26
+	MsgSupportedExtension uint8 = 31
+	MsgEndMarker          uint8 = 254
+	MsgGPDU               uint8 = 255
+)
+
+// Extension header types
+const (
+	ExtNoMore              uint8 = 0x00
+	ExtUDPPort             uint8 = 0x40
+	ExtPDUSessionContainer uint8 = 0x85
+)
+
+// PDU types of the PDU Session Container (TS 38.415)
+const (
+	PDUTypeDownlink uint8 = 0
+	PDUTypeUplink   uint8 = 1
+)
+
+// ExtensionHeader is one extension header. Content excludes the length and
+// next-type octets and is zero padded so the header is a multiple of 4 octets.
+type ExtensionHeader struct {
+	Type    uint8
+	Content []byte
+}
+
+// PDUSessionContainer returns the PDU Session Container extension header
+// carrying the QoS Flow Identifier of a downlink or uplink PDU. The PDU type
+// must fit in 4 bits and the QFI in 6.
+func PDUSessionContainer(pduType, qfi uint8) (ExtensionHeader, error) {
+	if pduType > 15 {
+		return ExtensionHeader{}, fmt.Errorf("PDU type %d does not fit in 4 bits", pduType)
+	}
+	if qfi > 63 {
+		return ExtensionHeader{}, fmt.Errorf("QFI %d does not fit in 6 bits", qfi)
+	}
+	return ExtensionHeader{
+		Type:    ExtPDUSessionContainer,
+		Content: []byte{pduType << 4, qfi},
+	}, nil
+}
+
+// Header is the GTP-U header. Fields are written as set: a zero Version or
+// ProtocolType produces a malformed packet, see NewGPDU for valid defaults.
+type Header struct {
+	Version      uint8 // 3 bits, Version1 for valid packets
+	ProtocolType uint8 // 1 bit, 1 for GTP
+	MessageType  uint8
+	TEID         uint32
+
+	// The sequence number, N-PDU number and extension headers are optional.
+	// When any of them is present the optional fields are all written.
+	SequenceFlag bool
+	Sequence     uint16
+	NPDUFlag     bool
+	NPDU         uint8
+	Extensions   []ExtensionHeader
+
+	// Length overrides the computed length field when non-nil, for packets
+	// whose length disagrees with their content
+	Length *uint16
+}
+
+// Packet is a GTP-U header and its payload, e.g. an inner IPv4 packet
+type Packet struct {
+	Header
+	Payload []byte
+}
+
+// NewGPDU returns a valid G-PDU carrying payload on the tunnel
+func NewGPDU(teid uint32, payload []byte) *Packet {
+	return &Packet{
+		Header: Header{
+			Version:      Version1,
+			ProtocolType: 1,
+			MessageType:  MsgGPDU,
+			TEID:         teid,
+		},
+		Payload: payload,
+	}
+}
+
+// hasOptional reports whether the optional fields follow the mandatory header
+func (h *Header) hasOptional() bool {
+	return h.SequenceFlag || h.NPDUFlag || len(h.Extensions) > 0
+}
+
+// Marshal serialises the packet
+func (p *Packet) Marshal() ([]byte, error) {
+	if p.Version > 7 {
+		return nil, fmt.Errorf("version %d does not fit in 3 bits", p.Version)
+	}
+	if p.ProtocolType > 1 {
+		return nil, fmt.Errorf("protocol type %d does not fit in 1 bit", p.ProtocolType)
+	}
+
+	var body []byte
+	if p.hasOptional() {
+		next := ExtNoMore
+		if len(p.Extensions) > 0 {
+			next = p.Extensions[0].Type
+		}
+		body = binary.BigEndian.AppendUint16(body, p.Sequence)
+		body = append(body, p.NPDU, next)
+		for i, ext := range p.Extensions {
+			next := ExtNoMore
+			if i+1 < len(p.Extensions) {
+				next = p.Extensions[i+1].Type
+			}
+			encoded, err := ext.marshal(next)
+			if err != nil {
+				return nil, err
+			}
+			body = append(body, encoded...)
+		}
+	}
+	body = append(body, p.Payload...)
+
+	length := uint16(len(body))
+	if len(body) > 0xffff {
+		return nil, fmt.Errorf("packet body of %d octets does not fit the length field", len(body))
+	}
+	if p.Length != nil {
+		length = *p.Length
+	}
+
+	flags := p.Version<<5 | p.ProtocolType<<4
+	if len(p.Extensions) > 0 {
+		flags |= 0x04
+	}
+	if p.SequenceFlag {
+		flags |= 0x02
+	}
+	if p.NPDUFlag {
+		flags |= 0x01
+	}
+
+	out := make([]byte, headerLen, headerLen+len(body))
+	out[0] = flags
+	out[1] = p.MessageType
+	binary.BigEndian.PutUint16(out[2:], length)
+	binary.BigEndian.PutUint32(out[4:], p.TEID)
+	return append(out, body...), nil
+}
+
+// marshal encodes the extension header followed by the type of the next one
+func (e ExtensionHeader) marshal(next uint8) ([]byte, error) {
+	size := len(e.Content) + 2
+	if pad := size % 4; pad != 0 {
+		size += 4 - pad
+	}
+	if size/4 > 0xff {
+		return nil, fmt.Errorf("extension header 0x%02x of %d octets is too long", e.Type, size)
+	}
+	out := make([]byte, size)
+	out[0] = uint8(size / 4)
+	copy(out[1:], e.Content)
+	out[size-1] = next
+	return out, nil
+}
@@ -0,0 +1,230 @@
+package gtpu
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+)
+
+// Inner packets shared by the GTP-U vectors
+var (
+	ueIP = net.IPv4(10, 45, 0, 2)
+	dnIP = net.IPv4(10, 45, 0, 1)
+
+	// 10.45.0.2:1234 -> 10.45.0.1:5678 UDP carrying "ping"
+	innerUDP = "4500002000000000401166710a2d00020a2d000104d2162e000cf1a870696e67"
+)
+
+// mustHex decodes a vector, ignoring spaces
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("bad vector %q: %v", s, err)
+	}
+	return b
+}
+
+// mustContainer builds a PDU Session Container extension header
+func mustContainer(t *testing.T, pduType, qfi uint8) ExtensionHeader {
+	t.Helper()
+	ext, err := PDUSessionContainer(pduType, qfi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ext
+}
+
+func expectBytes(t *testing.T, name string, got []byte, want string) {
+	t.Helper()
+	if w := mustHex(t, want); !bytes.Equal(got, w) {
+		t.Errorf("%s:\n got  %x\n want %x", name, got, w)
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	header := mustHex(t, "4500 0073 0000 4000 4011 0000 c0a8 0001 c0a8 00c7")
+	if got := Checksum(header); got != 0xb861 {
+		t.Errorf("Checksum = %#04x, want 0xb861", got)
+	}
+	if got := Checksum([]byte{0x01}); got != 0xfeff {
+		t.Errorf("Checksum of odd length = %#04x, want 0xfeff", got)
+	}
+}
+
+func TestInnerPackets(t *testing.T) {
+	ip := IPv4{
+		DontFragment: true,
+		TTL:          64,
+		Protocol:     ProtocolUDP,
+		Src:          net.IPv4(192, 168, 0, 1),
+		Dst:          net.IPv4(192, 168, 0, 199),
+		TotalLength:  0x73,
+	}
+	got, err := ip.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectBytes(t, "IPv4 header", got, "4500 0073 0000 4000 4011 b861 c0a8 0001 c0a8 00c7")
+
+	expectBytes(t, "ICMP echo", ICMPEcho(1, 1, []byte("abcdefghijklmnopqrstuvwabcdefghi")),
+		"0800 4d5a 0001 0001 6162636465666768696a6b6c6d6e6f7071727374757677616263646566676869")
+
+	got, err = IPv4UDP(ueIP, dnIP, 1234, 5678, []byte("ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectBytes(t, "IPv4/UDP", got, innerUDP)
+
+	malformed := IPv4{Version: 6, TTL: 64, Protocol: ProtocolUDP, Src: ueIP, Dst: dnIP, TotalLength: 0xffff, Checksum: 0xdead}
+	got, err = malformed.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectBytes(t, "malformed IPv4", got, "6500 ffff 0000 0000 4011 dead 0a2d0002 0a2d0001")
+
+	if _, err := (&IPv4{Src: net.ParseIP("::1"), Dst: dnIP}).Marshal(); err == nil {
+		t.Error("expected an error for an IPv6 source")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	inner := mustHex(t, innerUDP)
+	length := func(v uint16) *uint16 { return &v }
+
+	tests := []struct {
+		name   string
+		packet *Packet
+		want   string
+	}{
+		{
+			name:   "G-PDU",
+			packet: NewGPDU(1, inner),
+			want:   "30ff 0020 00000001" + innerUDP,
+		},
+		{
+			name: "G-PDU with downlink PDU Session Container",
+			packet: func() *Packet {
+				p := NewGPDU(0x12345678, inner)
+				p.Extensions = []ExtensionHeader{mustContainer(t, PDUTypeDownlink, 9)}
+				return p
+			}(),
+			want: "34ff 0028 12345678 0000 00 85 01 00 09 00" + innerUDP,
+		},
+		{
+			name: "G-PDU with sequence, N-PDU and uplink PDU Session Container",
+			packet: func() *Packet {
+				p := NewGPDU(7, inner)
+				p.SequenceFlag, p.Sequence = true, 0x0102
+				p.NPDUFlag, p.NPDU = true, 0x03
+				p.Extensions = []ExtensionHeader{mustContainer(t, PDUTypeUplink, 5)}
+				return p
+			}(),
+			want: "37ff 0028 00000007 0102 03 85 01 10 05 00" + innerUDP,
+		},
+		{
+			name: "chained extension headers",
+			packet: &Packet{
+				Header: Header{
+					Version: Version1, ProtocolType: 1, MessageType: MsgGPDU, TEID: 1,
+					Extensions: []ExtensionHeader{
+						{Type: ExtUDPPort, Content: []byte{0x08, 0x68}},
+						mustContainer(t, PDUTypeDownlink, 1),
+					},
+				},
+			},
+			want: "34ff 000c 00000001 0000 00 40 01 0868 85 01 00 01 00",
+		},
+		{
+			name: "echo request",
+			packet: &Packet{Header: Header{
+				Version: Version1, ProtocolType: 1, MessageType: MsgEchoRequest,
+				SequenceFlag: true, Sequence: 0x1234,
+			}},
+			want: "3201 0004 00000000 1234 00 00",
+		},
+		{
+			name: "end marker",
+			packet: &Packet{Header: Header{
+				Version: Version1, ProtocolType: 1, MessageType: MsgEndMarker, TEID: 0xdeadbeef,
+			}},
+			want: "30fe 0000 deadbeef",
+		},
+		{
+			name: "malformed version",
+			packet: func() *Packet {
+				p := NewGPDU(1, inner)
+				p.Version = 2
+				return p
+			}(),
+			want: "50ff 0020 00000001" + innerUDP,
+		},
+		{
+			name: "malformed protocol type",
+			packet: func() *Packet {
+				p := NewGPDU(1, inner)
+				p.ProtocolType = 0
+				return p
+			}(),
+			want: "20ff 0020 00000001" + innerUDP,
+		},
+		{
+			name: "length longer than the packet",
+			packet: func() *Packet {
+				p := NewGPDU(1, inner)
+				p.Length = length(0xffff)
+				return p
+			}(),
+			want: "30ff ffff 00000001" + innerUDP,
+		},
+		{
+			name: "length shorter than the optional fields",
+			packet: func() *Packet {
+				p := NewGPDU(1, inner)
+				p.Extensions = []ExtensionHeader{mustContainer(t, PDUTypeDownlink, 9)}
+				p.Length = length(2)
+				return p
+			}(),
+			want: "34ff 0002 00000001 0000 00 85 01 00 09 00" + innerUDP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.packet.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectBytes(t, tt.name, got, tt.want)
+		})
+	}
+}
+
+func TestMarshalRejectsOversizedFields(t *testing.T) {
+	p := NewGPDU(1, nil)
+	p.Version = 8
+	if _, err := p.Marshal(); err == nil {
+		t.Error("expected an error for a version wider than 3 bits")
+	}
+
+	p = NewGPDU(1, nil)
+	p.ProtocolType = 2
+	if _, err := p.Marshal(); err == nil {
+		t.Error("expected an error for a protocol type wider than 1 bit")
+	}
+
+	if _, err := PDUSessionContainer(PDUTypeUplink, 64); err == nil {
+		t.Error("expected an error for a QFI wider than 6 bits")
+	}
+	if _, err := PDUSessionContainer(16, 1); err == nil {
+		t.Error("expected an error for a PDU type wider than 4 bits")
+	}
+
+	p = NewGPDU(1, nil)
+	p.Extensions = []ExtensionHeader{{Type: ExtPDUSessionContainer, Content: make([]byte, 1024)}}
+	if _, err := p.Marshal(); err == nil {
+		t.Error("expected an error for an extension header longer than 1020 octets")
+	}
+}
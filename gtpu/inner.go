@@ -0,0 +1,135 @@
+package gtpu
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// IP protocol numbers of the inner packets
+const (
+	ProtocolICMP uint8 = 1
+	ProtocolTCP  uint8 = 6
+	ProtocolUDP  uint8 = 17
+)
+
+// ipv4HeaderLen is the length of an IPv4 header without options
+const ipv4HeaderLen = 20
+
+// IPv4 is an inner IPv4 packet without options. Version, TotalLength and
+// Checksum are computed when zero; set them to craft malformed packets.
+type IPv4 struct {
+	Version      uint8 // 4 when zero
+	TOS          uint8
+	ID           uint16
+	DontFragment bool
+	TTL          uint8
+	Protocol     uint8
+	Src          net.IP
+	Dst          net.IP
+	TotalLength  uint16 // Overrides the computed length when non-zero
+	Checksum     uint16 // Overrides the computed checksum when non-zero
+	Payload      []byte
+}
+
+// Marshal serialises the packet
+func (ip *IPv4) Marshal() ([]byte, error) {
+	src, dst := ip.Src.To4(), ip.Dst.To4()
+	if src == nil || dst == nil {
+		return nil, fmt.Errorf("source %v and destination %v must be IPv4 addresses", ip.Src, ip.Dst)
+	}
+	version := ip.Version
+	if version == 0 {
+		version = 4
+	}
+	if version > 15 {
+		return nil, fmt.Errorf("version %d does not fit in 4 bits", version)
+	}
+	if ipv4HeaderLen+len(ip.Payload) > 0xffff {
+		return nil, fmt.Errorf("payload of %d octets does not fit an IPv4 packet", len(ip.Payload))
+	}
+	totalLength := ip.TotalLength
+	if totalLength == 0 {
+		totalLength = uint16(ipv4HeaderLen + len(ip.Payload))
+	}
+
+	out := make([]byte, ipv4HeaderLen, ipv4HeaderLen+len(ip.Payload))
+	out[0] = version<<4 | ipv4HeaderLen/4
+	out[1] = ip.TOS
+	binary.BigEndian.PutUint16(out[2:], totalLength)
+	binary.BigEndian.PutUint16(out[4:], ip.ID)
+	if ip.DontFragment {
+		out[6] = 0x40
+	}
+	out[8] = ip.TTL
+	out[9] = ip.Protocol
+	copy(out[12:16], src)
+	copy(out[16:20], dst)
+	checksum := ip.Checksum
+	if checksum == 0 {
+		checksum = Checksum(out)
+	}
+	binary.BigEndian.PutUint16(out[10:], checksum)
+	return append(out, ip.Payload...), nil
+}
+
+// UDP returns a UDP datagram with its checksum computed over the IPv4
+// pseudo-header of src and dst
+func UDP(src, dst net.IP, srcPort, dstPort uint16, payload []byte) []byte {
+	out := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint16(out[0:], srcPort)
+	binary.BigEndian.PutUint16(out[2:], dstPort)
+	binary.BigEndian.PutUint16(out[4:], uint16(8+len(payload)))
+	out = append(out, payload...)
+
+	pseudo := make([]byte, 0, 12+len(out))
+	pseudo = append(pseudo, src.To4()...)
+	pseudo = append(pseudo, dst.To4()...)
+	pseudo = append(pseudo, 0, ProtocolUDP)
+	pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(out)))
+	pseudo = append(pseudo, out...)
+	checksum := Checksum(pseudo)
+	if checksum == 0 {
+		checksum = 0xffff // Zero means no checksum in UDP over IPv4
+	}
+	binary.BigEndian.PutUint16(out[6:], checksum)
+	return out
+}
+
+// ICMPEcho returns an ICMP echo request
+func ICMPEcho(id, seq uint16, payload []byte) []byte {
+	out := make([]byte, 8, 8+len(payload))
+	out[0] = 8 // Echo request
+	binary.BigEndian.PutUint16(out[4:], id)
+	binary.BigEndian.PutUint16(out[6:], seq)
+	out = append(out, payload...)
+	binary.BigEndian.PutUint16(out[2:], Checksum(out))
+	return out
+}
+
+// IPv4UDP returns a valid IPv4 packet carrying a UDP datagram
+func IPv4UDP(src, dst net.IP, srcPort, dstPort uint16, payload []byte) ([]byte, error) {
+	ip := IPv4{TTL: 64, Protocol: ProtocolUDP, Src: src, Dst: dst, Payload: UDP(src, dst, srcPort, dstPort, payload)}
+	return ip.Marshal()
+}
+
+// IPv4ICMPEcho returns a valid IPv4 packet carrying an ICMP echo request
+func IPv4ICMPEcho(src, dst net.IP, id, seq uint16, payload []byte) ([]byte, error) {
+	ip := IPv4{TTL: 64, Protocol: ProtocolICMP, Src: src, Dst: dst, Payload: ICMPEcho(id, seq, payload)}
+	return ip.Marshal()
+}
+
+// Checksum returns the Internet checksum (RFC 1071) of data
+func Checksum(data []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s-status-api/agent"
+	"k8s-status-api/k8s"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// agentDir holds the agent binary, its daemon log and the per-job logs inside the pod
+	agentDir = "/attack_agent"
+	// agentPath is the agent binary inside the pod
+	agentPath = agentDir + "/attack-agent"
+	// agentHashFile records the SHA-256 of the pushed binary so it is pushed once per build
+	agentHashFile = agentPath + ".sha256"
+	// agentDaemonLog receives the daemon's own output
+	agentDaemonLog = agentDir + "/agent.log"
+	// agentStartTimeout bounds the wait for a freshly started daemon to answer
+	agentStartTimeout = 10 * time.Second
+)
+
+// agentBinary is the static attack-agent build on the backend host. Empty
+// disables the attacks run by the agent.
+var agentBinary string
+
+// SetAgentBinary configures the attack agent binary pushed into pods
+func SetAgentBinary(path string) {
+	agentBinary = path
+}
+
+// agentCommand runs one of the agent's client commands in the pod and decodes
+// the status it prints. stdin is handed to the command when not nil.
+func agentCommand(clientset *kubernetes.Clientset, podName, container string, stdin []byte, command string) (agent.Status, error) {
+	opts := k8s.ExecOptions{
+		Container: container,
+		Command:   []string{agentPath, command},
+		Timeout:   defaultExecTimeout,
+	}
+	if stdin != nil {
+		opts.Stdin = bytes.NewReader(stdin)
+	}
+	result, err := k8s.Exec(context.Background(), clientset, podName, opts)
+	if err != nil {
+		return agent.Status{}, err
+	}
+	var status agent.Status
+	if err := json.Unmarshal([]byte(result.Stdout), &status); err != nil {
+		return agent.Status{}, fmt.Errorf("unexpected agent output %q: %v", strings.TrimSpace(result.Stdout), err)
+	}
+	return status, nil
+}
+
+// agentUnreachable reports whether a failed agent command only means that no
+// daemon runs in the pod, or that the agent was never pushed
+func agentUnreachable(err error) bool {
+	code := k8s.ExitCode(err)
+	return code == agent.ExitUnreachable || code == 126 || code == 127
+}
+
+// agentRunOf reports whether the status describes a run of the attack
+func agentRunOf(status agent.Status, attack *Attack) bool {
+	return status.Spec != nil && status.Spec.Attack == attack.Type
+}
+
+// pushAgent copies the agent binary into the pod unless the same build is
+// already there. A daemon of an older build is shut down so that the new
+// build is started in its place.
+func pushAgent(clientset *kubernetes.Clientset, podName, container, tag string) error {
+	data, err := os.ReadFile(agentBinary)
+	if err != nil {
+		return &attackError{"Failed to read attack agent binary", err}
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	if result, err := containerExec(clientset, podName, container, "cat", agentHashFile); err == nil && strings.TrimSpace(result.Stdout) == hash {
+		consoleLog("[%s] Attack agent already present in pod %s\n", tag, podName)
+		return nil
+	}
+
+	consoleLog("[%s] Pushing attack agent %s to pod %s\n", tag, agentBinary, podName)
+	// A running binary cannot be overwritten, so the new build is moved over it
+	staged := agentPath + ".new"
+	if err := writePodFile(clientset, podName, container, staged, data); err != nil {
+		return &attackError{"Failed to copy attack agent", err}
+	}
+	if _, err := containerExec(clientset, podName, container, "chmod", "+x", staged); err != nil {
+		return &attackError{"Failed to set attack agent permissions", err}
+	}
+	if _, err := containerExec(clientset, podName, container, "mv", "-f", staged, agentPath); err != nil {
+		return &attackError{"Failed to install attack agent", err}
+	}
+	if _, err := agentCommand(clientset, podName, container, nil, "shutdown"); err == nil {
+		consoleLog("[%s] Shut down the attack agent of the previous build in pod %s\n", tag, podName)
+	}
+	if err := writePodFile(clientset, podName, container, agentHashFile, []byte(hash+"\n")); err != nil {
+		consoleLog("[%s] Failed to record attack agent hash: %v\n", tag, err)
+	}
+	return nil
+}
+
+// ensureAgentDaemon returns the status of the pod's agent daemon, starting
+// the daemon first when none is running
+func ensureAgentDaemon(clientset *kubernetes.Clientset, podName, container, tag string) (agent.Status, error) {
+	status, err := agentCommand(clientset, podName, container, nil, "status")
+	if err == nil {
+		return status, nil
+	}
+	if !agentUnreachable(err) {
+		return agent.Status{}, &attackError{"Failed to query attack agent", err}
+	}
+
+	consoleLog("[%s] Starting attack agent in pod %s\n", tag, podName)
+	// The daemon outlives the exec session like the launcher's background scripts
+	launch := fmt.Sprintf("nohup %s serve >> %s 2>&1 &", agentPath, agentDaemonLog)
+	if _, err := containerExec(clientset, podName, container, "sh", "-c", launch); err != nil {
+		return agent.Status{}, &attackError{"Failed to start attack agent", err}
+	}
+	err = wait.PollUntilContextTimeout(context.Background(), 500*time.Millisecond, agentStartTimeout, true, func(ctx context.Context) (bool, error) {
+		status, err = agentCommand(clientset, podName, container, nil, "status")
+		if err != nil {
+			if agentUnreachable(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	})
+	if err != nil {
+		return agent.Status{}, &attackError{"Attack agent did not start", fmt.Errorf("see %s in the pod: %v", agentDaemonLog, err)}
+	}
+	return status, nil
+}
+
+// agentSpec builds the agent run of a job from its request
+func agentSpec(attack *Attack, req AttackRequest, job *attackJob) agent.Spec {
+	spec := agent.Spec{
+		ID:              job.ID,
+		Attack:          attack.Type,
+		Mode:            req.Params.Mode,
+		Target:          req.TargetIP,
+		DstPort:         req.Params.DstPort,
+		PacketRate:      req.Params.PacketRate,
+		PayloadSize:     req.Params.PayloadSize,
+		Threads:         req.Params.Threads,
+		DurationSeconds: req.Params.Duration,
+		LogFile:         job.LogFile,
+	}
+	if !attack.PodNetwork {
+		spec.Interface = tunnelInterface
+	}
+	if spec.Mode == agent.ModeGTPU {
+		spec.InnerDst = gtpEncapsulationInnerDst
+		spec.TEIDMin, spec.TEIDMax = 1, 1
+		if req.Params.TEIDRange != "" {
+			min, max, _ := parseRange(req.Params.TEIDRange, 0, 0xFFFFFFFF)
+			spec.TEIDMin, spec.TEIDMax = uint32(min), uint32(max)
+		}
+	}
+	return spec
+}
+
+// prepareAgentAttack pushes the agent into the pod if needed and makes sure
+// its daemon is running
+func prepareAgentAttack(clientset *kubernetes.Clientset, attack *Attack, req AttackRequest, job *attackJob) error {
+	tag := attack.LogTag
+	if _, err := containerExec(clientset, req.PodName, job.Container, "mkdir", "-p", agentDir, attack.logDir()); err != nil {
+		consoleLog("[ERROR] Error creating directory: %v\n", err)
+		return &attackError{"Failed to create directory", err}
+	}
+	if err := pushAgent(clientset, req.PodName, job.Container, tag); err != nil {
+		return err
+	}
+	_, err := ensureAgentDaemon(clientset, req.PodName, job.Container, tag)
+	return err
+}
+
+// execAgentAttack starts the job's run in the prepared agent, returning the PID
+// of the agent daemon. A run of the same attack is replaced, like a relaunched
+// script.
+func execAgentAttack(clientset *kubernetes.Clientset, attack *Attack, req AttackRequest, job *attackJob) (string, error) {
+	tag := attack.LogTag
+	status, err := agentCommand(clientset, req.PodName, job.Container, nil, "status")
+	if err != nil {
+		return "", &attackError{"Failed to query attack agent", err}
+	}
+	if status.Running && agentRunOf(status, attack) {
+		consoleLog("[%s] Replacing agent run %s in pod %s\n", tag, status.Spec.ID, req.PodName)
+		if _, err := agentCommand(clientset, req.PodName, job.Container, nil, "stop"); err != nil {
+			return "", &attackError{"Failed to stop previous agent run", err}
+		}
+	}
+
+	spec, err := json.Marshal(agentSpec(attack, req, job))
+	if err != nil {
+		return "", &attackError{"Failed to encode agent run", err}
+	}
+	consoleLog("[%s] Starting %s in the attack agent with target %q and params %+v...\n", tag, attack.Name, req.TargetIP, req.Params)
+	status, err = agentCommand(clientset, req.PodName, job.Container, spec, "start")
+	if err != nil {
+		consoleLog("[ERROR] Error starting agent run: %v\n", err)
+		return "", &attackError{fmt.Sprintf("Failed to run %s", attack.Name), err}
+	}
+
+	// Record the launch parameters for status requests
+	launch, _ := json.Marshal(attackLaunch{TargetIP: req.TargetIP, Params: req.Params, StartedAt: time.Now()})
+	if err := writePodFile(clientset, req.PodName, job.Container, attack.launchFile(), launch); err != nil {
+		consoleLog("[%s] Failed to record launch parameters: %v\n", tag, err)
+	}
+
+	pid := strconv.Itoa(status.PID)
+	consoleLog("[SUCCESS] %s started successfully in attack agent %s!\n", attack.Name, pid)
+	return pid, nil
+}
+
+// stopAgentAttack stops the agent's run of the attack, returning the ID of
+// the stopped run
+func stopAgentAttack(clientset *kubernetes.Clientset, attack *Attack, podName, container string) []string {
+	tag := attack.LogTag
+	consoleLog("[%s] Stopping %s in the attack agent of pod: %s\n", tag, attack.Name, podName)
+
+	status, err := agentCommand(clientset, podName, container, nil, "status")
+	if err != nil {
+		if !agentUnreachable(err) {
+			consoleLog("[ERROR] Error querying attack agent: %v\n", err)
+		}
+		return nil
+	}
+	if !status.Running || !agentRunOf(status, attack) {
+		consoleLog("[%s] No %s is running in the attack agent\n", tag, attack.Name)
+		return nil
+	}
+	status, err = agentCommand(clientset, podName, container, nil, "stop")
+	if err != nil {
+		consoleLog("[ERROR] Error stopping agent run: %v\n", err)
+		return nil
+	}
+
+	consoleLog("[SUCCESS] %s stopped after %d packets!\n", attack.Name, status.Counters.Packets)
+	return []string{status.Spec.ID}
+}
+
+// checkAgentAttack reports whether the agent is running the attack
+func checkAgentAttack(clientset *kubernetes.Clientset, attack *Attack, podName, container string) (attackStatus, error) {
+	status, err := agentCommand(clientset, podName, container, nil, "status")
+	if err != nil {
+		if agentUnreachable(err) {
+			return attackStatus{}, nil
+		}
+		return attackStatus{}, &attackError{fmt.Sprintf("Failed to check %s status", attack.Name), err}
+	}
+	if !status.Running || !agentRunOf(status, attack) {
+		return attackStatus{}, nil
+	}
+	return attackStatus{
+		Running: true,
+		PID:     strconv.Itoa(status.PID),
+		Launch:  readAttackLaunch(clientset, attack, podName, container),
+	}, nil
+}
+
+// agentRunAlive checks whether the agent is still running the job's run
+func agentRunAlive(clientset *kubernetes.Clientset, podName, container, jobID string) (bool, error) {
+	status, err := agentCommand(clientset, podName, container, nil, "status")
+	if err != nil {
+		if agentUnreachable(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return status.Running && status.Spec != nil && status.Spec.ID == jobID, nil
+}
+
+// agentReport adds the agent's live counters to the status of a job
+func agentReport(clientset *kubernetes.Clientset, job *attackJob) (map[string]interface{}, error) {
+	status, err := agentCommand(clientset, job.PodName, job.Container, nil, "status")
+	if err != nil {
+		return nil, err
+	}
+	if status.Spec == nil || status.Spec.ID != job.ID {
+		return nil, fmt.Errorf("the attack agent no longer holds job %s", job.ID)
+	}
+	report := map[string]interface{}{
+		"agentPid":      status.PID,
+		"running":       status.Running,
+		"packets":       status.Counters.Packets,
+		"bytes":         status.Counters.Bytes,
+		"errors":        status.Counters.Errors,
+		"packetsPerSec": status.PacketsPerSec,
+	}
+	if status.ExitReason != "" {
+		report["exitReason"] = status.ExitReason
+	}
+	if status.Error != "" {
+		report["error"] = status.Error
+	}
+	return report, nil
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"k8s-status-api/agent"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/kubernetes"
+)
+
+// gtpEncapsulationInnerDst is the address the inner packets of GTP-in-GTP
+// encapsulation are sent to, as in the gtp_encapsulation script
+const gtpEncapsulationInnerDst = "10.45.0.1"
+
+func init() {
+	RegisterAttack(&Attack{
+		Type:           "agent-ddos",
+		Name:           "Agent DDoS flood",
+		LogTag:         "AGENT-DDOS",
+		Label:          "DDoS",
+		WorkDir:        agentDir,
+		Agent:          true,
+		TargetRequired: true,
+		Report:         agentReport,
+		Defaults:       AttackParams{Mode: agent.ModeICMP},
+		Modes: map[string]attackMode{
+			agent.ModeICMP: {Params: []string{ParamPacketRate, ParamPayloadSize, ParamThreads, ParamDuration}, Defaults: AttackParams{PayloadSize: 56}},
+			agent.ModeUDP:  {Params: []string{ParamPacketRate, ParamPayloadSize, ParamDstPort, ParamThreads, ParamDuration}, Defaults: AttackParams{PayloadSize: 512, DstPort: 53}},
+		},
+	})
+
+	RegisterAttack(&Attack{
+		Type:           "agent-gtp-encapsulation",
+		Name:           "Agent GTP Encapsulation attack",
+		LogTag:         "AGENT-GTP",
+		Label:          "GTP_ENCAPSULATION",
+		WorkDir:        agentDir,
+		Agent:          true,
+		TargetRequired: true,
+		Report:         agentReport,
+		Defaults:       AttackParams{Mode: agent.ModeGTPU},
+		Modes: map[string]attackMode{
+			agent.ModeGTPU: {
+				Params:   []string{ParamPacketRate, ParamPayloadSize, ParamTEIDRange, ParamThreads, ParamDuration},
+				Defaults: AttackParams{PacketRate: 100, PayloadSize: 17, TEIDRange: "1"},
+			},
+		},
+	})
+}
+
+// RunAgentDDoSAttack handles executing a DDoS flood from the pod's attack agent
+func RunAgentDDoSAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "agent-ddos", runAttack)
+}
+
+// StopAgentDDoSAttack handles stopping the attack agent's DDoS flood
+func StopAgentDDoSAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "agent-ddos", stopAttackHandler)
+}
+
+// CheckAgentDDoSAttackStatus checks if the attack agent's DDoS flood is running
+func CheckAgentDDoSAttackStatus(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "agent-ddos", checkAttackStatusHandler)
+}
+
+// RunAgentGTPEncapsulationAttack handles executing a GTP Encapsulation attack from the pod's attack agent
+func RunAgentGTPEncapsulationAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "agent-gtp-encapsulation", runAttack)
+}
+
+// StopAgentGTPEncapsulationAttack handles stopping the attack agent's GTP Encapsulation attack
+func StopAgentGTPEncapsulationAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "agent-gtp-encapsulation", stopAttackHandler)
+}
+
+// CheckAgentGTPEncapsulationAttackStatus checks if the attack agent's GTP Encapsulation attack is running
+func CheckAgentGTPEncapsulationAttackStatus(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "agent-gtp-encapsulation", checkAttackStatusHandler)
+}
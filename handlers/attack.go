@@ -0,0 +1,782 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s-status-api/k8s"
+	"k8s-status-api/scripts"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultExecTimeout bounds short commands run inside pods
+	defaultExecTimeout = 2 * time.Minute
+	// installExecTimeout bounds package installation inside pods
+	installExecTimeout = 10 * time.Minute
+)
+
+// Attack describes an attack type that is launched from a UE pod by copying a
+// script into the pod and starting it in the background with a launcher
+// script, or by handing it to the pod's attack agent
+type Attack struct {
+	Type           string   // Registry key used in the /attacks/{type} routes
+	Name           string   // Human readable name used in messages
+	LogTag         string   // Console prefix, e.g. "DDOS"
+	Label          string   // Class name written to labelled datasets, e.g. "DDoS"
+	Script         string   // Script path within the scripts package, e.g. "attacks/ddos_attack.py"
+	WorkDir        string   // Directory inside the pod holding the script, launcher and PID file
+	ScriptName     string   // File name of the script inside WorkDir
+	Launcher       string   // File name of the launcher script inside WorkDir
+	PIDFile        string   // File name of the PID file inside WorkDir
+	TargetRequired bool     // Whether a run request must carry a target IP
+	ExtraProcesses []string // Additional pgrep patterns killed when the attack is stopped
+	Tools          []string // Binaries the script needs on PATH, installed when missing
+	RequiredTools  []string // Binaries the pod image must provide, as no package installs them; preflight fails without them
+	PythonModules  []string // Python modules the script imports, installed when missing
+	PodNetwork     bool     // Sends over the pod network instead of uesimtun0, so no PDU session is needed
+	Agent          bool     // Runs natively in the attack agent, the mode parameter selecting the agent's mode; no script is used
+
+	// Report optionally adds attack specific measurements to the status of a job
+	Report func(clientset *kubernetes.Clientset, job *attackJob) (map[string]interface{}, error)
+
+	Params   []string     // Names of the AttackParams the script honours
+	Defaults AttackParams // Values applied to parameters the request leaves unset
+
+	// Modes optionally splits the attack into variants selected by the mode
+	// parameter, each with its own parameters. Params is then unused and
+	// Defaults.Mode names the default mode.
+	Modes map[string]attackMode
+}
+
+// AttackRequest represents the request payload for attack operations. It
+// addresses either a single podName or, in botnet mode, every pod listed in
+// pods and matched by selector and podPattern.
+type AttackRequest struct {
+	PodName         string       `json:"podName" form:"podName"`
+	Pods            []string     `json:"pods" form:"pods"`
+	Selector        string       `json:"selector" form:"selector"`       // Kubernetes label selector, e.g. "app=ueransim-ue"
+	PodPattern      string       `json:"podPattern" form:"podPattern"`   // Pod name glob, e.g. "ueransim-ue*"
+	Concurrency     int          `json:"concurrency" form:"concurrency"` // Pods handled at once, defaults to defaultFanOutConcurrency
+	TargetIP        string       `json:"targetIP" form:"targetIP"`
+	Params          AttackParams `json:"params"`
+	DurationSeconds int          `json:"durationSeconds" form:"durationSeconds"` // Stop the attack after this many seconds, 0 runs until stopped
+	Ephemeral       bool         `json:"ephemeral" form:"ephemeral"`             // Run in an ephemeral tools container instead of the UE container
+	MonitorPod      string       `json:"monitorPod" form:"monitorPod"`           // Also sample this pod's interfaces while the attack runs, e.g. the UPF
+	AllowConcurrent bool         `json:"allowConcurrent" form:"allowConcurrent"` // Share the pod with other attacks that allow it
+	QueueSeconds    int          `json:"queueSeconds" form:"queueSeconds"`       // Wait this long for conflicting jobs to end, 0 rejects the launch at once
+}
+
+// attackLaunch is saved next to the PID file so that status can report what
+// was launched
+type attackLaunch struct {
+	TargetIP  string       `json:"targetIP,omitempty"`
+	Params    AttackParams `json:"params"`
+	StartedAt time.Time    `json:"startedAt"`
+}
+
+// attackError describes a failed step while launching an attack, mirroring the
+// error/details fields returned to the client
+type attackError struct {
+	Message string
+	Err     error
+}
+
+func (e *attackError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Message, e.Err)
+}
+
+func (e *attackError) Unwrap() error {
+	return e.Err
+}
+
+// attackRegistry holds every registered attack type keyed by Attack.Type
+var attackRegistry = map[string]*Attack{}
+
+// RegisterAttack adds an attack definition to the registry
+func RegisterAttack(attack *Attack) *Attack {
+	if _, exists := attackRegistry[attack.Type]; exists {
+		panic(fmt.Sprintf("attack type %q registered twice", attack.Type))
+	}
+	// Attacks sharing a work directory must not share files, or concurrent
+	// jobs in a pod would overwrite each other's launcher and PID
+	for _, other := range attackRegistry {
+		if attack.Agent || other.Agent || attack.WorkDir != other.WorkDir {
+			continue
+		}
+		if attack.ScriptName == other.ScriptName || attack.Launcher == other.Launcher || attack.PIDFile == other.PIDFile {
+			panic(fmt.Sprintf("attack types %q and %q share files in %s", attack.Type, other.Type, attack.WorkDir))
+		}
+	}
+	attackRegistry[attack.Type] = attack
+	return attack
+}
+
+// LookupAttack returns the registered attack for the given type
+func LookupAttack(attackType string) (*Attack, bool) {
+	attack, ok := attackRegistry[attackType]
+	return attack, ok
+}
+
+// registeredAttacks returns all registered attacks sorted by type
+func registeredAttacks() []*Attack {
+	attacks := make([]*Attack, 0, len(attackRegistry))
+	for _, attack := range attackRegistry {
+		attacks = append(attacks, attack)
+	}
+	sort.Slice(attacks, func(i, j int) bool { return attacks[i].Type < attacks[j].Type })
+	return attacks
+}
+
+func (a *Attack) scriptFile() string   { return a.WorkDir + "/" + a.ScriptName }
+func (a *Attack) launcherFile() string { return a.WorkDir + "/" + a.Launcher }
+func (a *Attack) pidFile() string      { return a.WorkDir + "/" + a.PIDFile }
+func (a *Attack) launchFile() string   { return a.WorkDir + "/" + a.Type + ".launch.json" }
+func (a *Attack) logDir() string       { return a.WorkDir + "/logs" }
+
+// logFile is the file receiving the output of one job
+func (a *Attack) logFile(jobID string) string { return a.logDir() + "/" + jobID + ".log" }
+
+// processPattern is the pgrep pattern matching the running attack script
+func (a *Attack) processPattern() string { return "python3.*" + a.ScriptName }
+
+// consoleLog is a helper function to write logs with forced flush
+func consoleLog(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	fmt.Print(message)
+	os.Stdout.Sync() // Force flush
+}
+
+// podExec runs a command inside the pod's default container through the exec API
+func podExec(clientset *kubernetes.Clientset, podName string, command ...string) (*k8s.ExecResult, error) {
+	return podExecTimeout(clientset, podName, defaultExecTimeout, command...)
+}
+
+// podExecTimeout is podExec with an explicit timeout
+func podExecTimeout(clientset *kubernetes.Clientset, podName string, timeout time.Duration, command ...string) (*k8s.ExecResult, error) {
+	return k8s.Exec(context.Background(), clientset, podName, k8s.ExecOptions{
+		Command: command,
+		Timeout: timeout,
+	})
+}
+
+// containerExec is podExec for a given container, an empty name selects the
+// pod's default container
+func containerExec(clientset *kubernetes.Clientset, podName, container string, command ...string) (*k8s.ExecResult, error) {
+	return k8s.Exec(context.Background(), clientset, podName, k8s.ExecOptions{
+		Container: container,
+		Command:   command,
+		Timeout:   defaultExecTimeout,
+	})
+}
+
+// copyScriptToPod streams an embedded (or overridden) script into the pod
+func copyScriptToPod(clientset *kubernetes.Clientset, podName, container, script, remotePath string) error {
+	data, overridden, err := scripts.Read(script)
+	if err != nil {
+		return fmt.Errorf("failed to read script %s: %v", script, err)
+	}
+	if overridden {
+		consoleLog("[SCRIPTS] Using %s from override directory %s\n", script, scripts.OverrideDir())
+	}
+	return writePodFile(clientset, podName, container, remotePath, data)
+}
+
+// writePodFile writes data to a file inside the pod
+func writePodFile(clientset *kubernetes.Clientset, podName, container, remotePath string, data []byte) error {
+	opts := k8s.ExecOptions{Container: container, Timeout: defaultExecTimeout}
+	return k8s.CopyToPod(context.Background(), clientset, podName, opts, bytes.NewReader(data), remotePath)
+}
+
+// noProcessFound reports whether a failed pgrep only means that nothing matched
+func noProcessFound(err error) bool {
+	return k8s.ExitCode(err) == 1
+}
+
+// errorDetails returns the structured details of an exec failure, or its message
+func errorDetails(err error) interface{} {
+	if report, ok := preflightDetails(err); ok {
+		return report
+	}
+	if holders, ok := podConflictDetails(err); ok {
+		return gin.H{"message": err.Error(), "podJobs": holders}
+	}
+	var execErr *k8s.ExecError
+	if errors.As(err, &execErr) {
+		return execErr.Details()
+	}
+	return err.Error()
+}
+
+// normalize validates a run request against the attack definition and fills
+// in the default parameters
+func (a *Attack) normalize(req *AttackRequest) error {
+	if a.TargetRequired && req.TargetIP == "" {
+		return fmt.Errorf("targetIP is required for %s", a.Name)
+	}
+	if req.TargetIP != "" {
+		if err := validateTargetIP(req.TargetIP); err != nil {
+			return err
+		}
+	}
+	if req.MonitorPod != "" {
+		if err := validatePodName("monitorPod", req.MonitorPod); err != nil {
+			return err
+		}
+	}
+	if err := a.validateParams(req.Params); err != nil {
+		return err
+	}
+	if req.DurationSeconds < 0 || req.DurationSeconds > maxDuration {
+		return fmt.Errorf("durationSeconds must be between 0 and %d", maxDuration)
+	}
+	if req.QueueSeconds < 0 || req.QueueSeconds > maxQueueSeconds {
+		return fmt.Errorf("queueSeconds must be between 0 and %d", maxQueueSeconds)
+	}
+	if req.Ephemeral && ephemeralImage == "" {
+		return fmt.Errorf("ephemeral runs need an ephemeral image to be configured")
+	}
+	if req.Ephemeral && len(a.RequiredTools) > 0 {
+		// The tools image cannot provide binaries that only the pod's image ships
+		return fmt.Errorf("%s cannot run in an ephemeral container, it needs %s from the pod's image", a.Name, strings.Join(a.RequiredTools, ", "))
+	}
+	if a.Agent && agentBinary == "" {
+		return fmt.Errorf("%s needs an attack agent binary to be configured", a.Name)
+	}
+	req.Params = a.withAttackDefaults(req.Params)
+	return nil
+}
+
+// launcherScript builds the launcher that hands the parameters to the attack
+// script through its environment, sends its output to logFile and prints the
+// PID of the detached process
+func (a *Attack) launcherScript(req AttackRequest, logFile string) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/bash\n")
+	for _, kv := range scriptEnv(req.TargetIP, req.Params) {
+		name, value, _ := strings.Cut(kv, "=")
+		fmt.Fprintf(&b, "export %s=%s\n", name, shellQuote(value))
+	}
+	// -u keeps the output unbuffered so the log can be followed live
+	fmt.Fprintf(&b, "python3 -u %s > %s 2>&1 &\necho $!\n", a.scriptFile(), shellQuote(logFile))
+	return b.String()
+}
+
+// prepareAttack installs the attack's dependencies in the pod and copies its
+// script and launcher, ready for execAttack. A non-empty job container runs the
+// attack in that ephemeral container, whose image already has the tools
+// installed. Agent attacks get the attack agent instead.
+func prepareAttack(clientset *kubernetes.Clientset, attack *Attack, req AttackRequest, job *attackJob) error {
+	tag := attack.LogTag
+	container, logFile := job.Container, job.LogFile
+	consoleLog("[%s] Starting %s setup for pod: %s\n", tag, attack.Name, req.PodName)
+
+	target := attackPreflightTarget(attack, req.TargetIP)
+	if container != "" {
+		// Preflight runs in the UE container, whose tools the attack will not use
+		target.Tools, target.PythonModules = nil, nil
+	}
+	if err := checkPreflight(clientset, req.PodName, tag, target); err != nil {
+		return err
+	}
+	if attack.Agent {
+		return prepareAgentAttack(clientset, attack, req, job)
+	}
+
+	// Step 1: Install the tools that are missing
+	if container == "" {
+		if err := provisionTools(clientset, req.PodName, tag, attack.Tools, attack.PythonModules); err != nil {
+			return err
+		}
+	} else {
+		consoleLog("[%s] Using tools of ephemeral container %s\n", tag, container)
+	}
+
+	// Step 2: Create directory for attack script
+	consoleLog("[%s] Creating directory for attack script...\n", tag)
+	if _, err := containerExec(clientset, req.PodName, container, "mkdir", "-p", attack.WorkDir, attack.logDir()); err != nil {
+		consoleLog("[ERROR] Error creating directory: %v\n", err)
+		return &attackError{"Failed to create directory", err}
+	}
+
+	// Step 3: Copy attack script to pod
+	consoleLog("[%s] Copying attack script to pod...\n", tag)
+	if err := copyScriptToPod(clientset, req.PodName, container, attack.Script, attack.scriptFile()); err != nil {
+		consoleLog("[ERROR] Error copying script: %v\n", err)
+		return &attackError{"Failed to copy attack script", err}
+	}
+
+	// Step 4: Create a launch script that passes the parameters and daemonizes the process
+	consoleLog("[%s] Creating launcher script with target %q and params %+v...\n", tag, req.TargetIP, req.Params)
+	if err := writePodFile(clientset, req.PodName, container, attack.launcherFile(), []byte(attack.launcherScript(req, logFile))); err != nil {
+		consoleLog("[ERROR] Error creating launcher script: %v\n", err)
+		return &attackError{"Failed to create launcher script", err}
+	}
+
+	// Make launcher script executable
+	if _, err := containerExec(clientset, req.PodName, container, "chmod", "+x", attack.launcherFile()); err != nil {
+		consoleLog("[ERROR] Error setting script permissions: %v\n", err)
+		return &attackError{"Failed to set launcher script permissions", err}
+	}
+	return nil
+}
+
+// execAttack launches the prepared attack in the background, returning the PID
+// of the detached process. The script's output goes to the job's log file.
+func execAttack(clientset *kubernetes.Clientset, attack *Attack, req AttackRequest, job *attackJob) (string, error) {
+	if attack.Agent {
+		return execAgentAttack(clientset, attack, req, job)
+	}
+	tag, container := attack.LogTag, job.Container
+
+	// Step 5: Launch the attack script using the launcher script
+	consoleLog("[%s] Starting %s...\n", tag, attack.Name)
+	result, err := containerExec(clientset, req.PodName, container, attack.launcherFile())
+	if err != nil {
+		consoleLog("[ERROR] Error running attack: %v\n", err)
+		return "", &attackError{fmt.Sprintf("Failed to run %s", attack.Name), err}
+	}
+
+	// Save the process ID to a file for easier management
+	pid := strings.TrimSpace(result.Stdout)
+	if pid != "" {
+		writePodFile(clientset, req.PodName, container, attack.pidFile(), []byte(pid+"\n")) // We don't need to check for errors here
+	}
+
+	// Record the launch parameters for status requests
+	launch, _ := json.Marshal(attackLaunch{TargetIP: req.TargetIP, Params: req.Params, StartedAt: time.Now()})
+	if err := writePodFile(clientset, req.PodName, container, attack.launchFile(), launch); err != nil {
+		consoleLog("[%s] Failed to record launch parameters: %v\n", tag, err)
+	}
+
+	consoleLog("[SUCCESS] %s started successfully with PID: %s!\n", attack.Name, pid)
+	return pid, nil
+}
+
+// stopAttack kills the saved attack process and any other process matching the
+// attack's patterns, returning the PIDs that were killed. Agent attacks are
+// stopped through the agent, returning the ID of the stopped run.
+func stopAttack(clientset *kubernetes.Clientset, attack *Attack, podName, container string) []string {
+	if attack.Agent {
+		return stopAgentAttack(clientset, attack, podName, container)
+	}
+	tag := attack.LogTag
+	consoleLog("[%s] Stopping %s for pod: %s\n", tag, attack.Name, podName)
+
+	var killed []string
+	kill := func(pid string) {
+		consoleLog("[%s] Killing process with PID: %s\n", tag, pid)
+		if _, err := containerExec(clientset, podName, container, "kill", "-9", pid); err == nil {
+			killed = append(killed, pid)
+		}
+	}
+
+	// Check if we have a saved PID file
+	if pid := readAttackPID(clientset, attack, podName, container); pid != "" {
+		consoleLog("[%s] Found saved PID: %s. Killing process...\n", tag, pid)
+		kill(pid)
+	}
+
+	// Find and kill any processes still running the attack
+	consoleLog("[%s] Finding other attack processes...\n", tag)
+	for _, pattern := range append([]string{attack.processPattern()}, attack.ExtraProcesses...) {
+		result, err := containerExec(clientset, podName, container, "pgrep", "-f", pattern)
+		if err != nil {
+			if !noProcessFound(err) {
+				consoleLog("[ERROR] Error finding process: %v\n", err)
+			}
+			continue
+		}
+		for _, pid := range strings.Split(strings.TrimSpace(result.Stdout), "\n") {
+			if pid != "" {
+				kill(pid)
+			}
+		}
+	}
+
+	consoleLog("[SUCCESS] %s stopped successfully!\n", attack.Name)
+	return killed
+}
+
+// readAttackPID returns the PID saved by the launcher, or an empty string
+func readAttackPID(clientset *kubernetes.Clientset, attack *Attack, podName, container string) string {
+	result, err := containerExec(clientset, podName, container, "bash", "-c",
+		fmt.Sprintf("if [ -f %[1]s ]; then cat %[1]s; else echo ''; fi", attack.pidFile()))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(result.Stdout)
+}
+
+// readAttackLaunch returns the parameters recorded when the attack was started
+func readAttackLaunch(clientset *kubernetes.Clientset, attack *Attack, podName, container string) *attackLaunch {
+	result, err := containerExec(clientset, podName, container, "cat", attack.launchFile())
+	if err != nil {
+		return nil
+	}
+	var launch attackLaunch
+	if err := json.Unmarshal([]byte(result.Stdout), &launch); err != nil {
+		return nil
+	}
+	return &launch
+}
+
+// attackStatus reports whether an attack is running in a pod
+type attackStatus struct {
+	Running bool
+	PID     string
+	Launch  *attackLaunch
+}
+
+// checkAttack checks the saved PID first and falls back to pgrep. Agent
+// attacks ask the agent.
+func checkAttack(clientset *kubernetes.Clientset, attack *Attack, podName, container string) (attackStatus, error) {
+	if attack.Agent {
+		return checkAgentAttack(clientset, attack, podName, container)
+	}
+	if pid := readAttackPID(clientset, attack, podName, container); pid != "" {
+		// Check if the process with this PID is still running
+		if _, err := containerExec(clientset, podName, container, "ps", "-p", pid); err == nil {
+			return attackStatus{Running: true, PID: pid, Launch: readAttackLaunch(clientset, attack, podName, container)}, nil
+		}
+	}
+
+	// If we don't have a PID file or the saved PID doesn't correspond to a running process,
+	// check for any running attack processes
+	if _, err := containerExec(clientset, podName, container, "pgrep", "-f", attack.processPattern()); err != nil {
+		if noProcessFound(err) {
+			return attackStatus{}, nil
+		}
+		return attackStatus{}, &attackError{fmt.Sprintf("Failed to check %s status", attack.Name), err}
+	}
+	return attackStatus{Running: true, Launch: readAttackLaunch(clientset, attack, podName, container)}, nil
+}
+
+// respondAttackError writes a launch or status failure as JSON
+func respondAttackError(c *gin.Context, err error) {
+	var ae *attackError
+	if errors.As(err, &ae) {
+		status := http.StatusInternalServerError
+		if _, ok := podConflictDetails(ae.Err); ok {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   ae.Message,
+			"details": errorDetails(ae.Err),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// bindAttackRequest binds the request from the JSON body, or from the query
+// string for body-less GET requests
+func bindAttackRequest(c *gin.Context, req *AttackRequest) error {
+	if c.Request.Method == http.MethodGet && c.Request.ContentLength <= 0 {
+		return c.ShouldBindQuery(req)
+	}
+	return c.ShouldBindJSON(req)
+}
+
+// attackFromParam resolves the attack named by the :type route parameter
+func attackFromParam(c *gin.Context) (*Attack, bool) {
+	attack, ok := LookupAttack(c.Param("type"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Unknown attack type: %s", c.Param("type"))})
+	}
+	return attack, ok
+}
+
+// launchAttackOnPod starts the attack in one pod and returns the response
+// describing the launch
+func launchAttackOnPod(clientset *kubernetes.Clientset, attack *Attack, req AttackRequest) (gin.H, error) {
+	job, err := launchAttack(clientset, attack, req)
+	if err != nil {
+		return nil, err
+	}
+	pid := job.PID
+	consoleLog("[%s] Tracking job %s, %s\n", attack.LogTag, job.ID, describeDeadline(job))
+
+	message := fmt.Sprintf("%s started successfully", attack.Name)
+	if req.TargetIP != "" {
+		message = fmt.Sprintf("%s against %s", message, req.TargetIP)
+	}
+	response := gin.H{
+		"message":  message,
+		"pid":      pid,
+		"targetIP": req.TargetIP,
+		"params":   req.Params,
+		"jobId":    job.ID,
+	}
+	if !job.Deadline.IsZero() {
+		response["deadline"] = job.Deadline
+	}
+	return response, nil
+}
+
+// stopAttackOnPod stops the attack in one pod and returns the killed PIDs
+func stopAttackOnPod(clientset *kubernetes.Clientset, attack *Attack, podName string) gin.H {
+	unlock := lockPod(podName)
+	defer unlock()
+	// Mark the job first so its watcher does not report the kill as a crash
+	if job := currentAttackJob(attack, podName); job != nil {
+		finishAttackJob(job, exitStopped)
+	}
+	killed := stopAttack(clientset, attack, podName, attackContainerFor(attack, podName))
+	return gin.H{
+		"message": fmt.Sprintf("%s stopped successfully", attack.Name),
+		"killed":  killed,
+	}
+}
+
+// attackStatusOnPod reports whether the attack is running in one pod, and
+// every job holding the pod
+func attackStatusOnPod(clientset *kubernetes.Clientset, attack *Attack, podName string) (gin.H, error) {
+	consoleLog("[STATUS] Checking %s status for pod: %s\n", attack.Name, podName)
+	status, err := checkAttack(clientset, attack, podName, attackContainerFor(attack, podName))
+	if err != nil {
+		consoleLog("[ERROR] Error checking process status: %v\n", err)
+		return nil, err
+	}
+
+	if !status.Running {
+		consoleLog("[STATUS] No %s is currently running.\n", attack.Name)
+		response := gin.H{"status": "not running"}
+		addJobStatus(response, currentAttackJob(attack, podName))
+		addAttackReport(clientset, response, currentAttackJob(attack, podName))
+		response["podJobs"] = podLeaseHolders(podName)
+		return response, nil
+	}
+
+	consoleLog("[STATUS] %s is running.\n", attack.Name)
+	response := gin.H{"status": "running"}
+	if status.PID != "" {
+		response["pid"] = status.PID
+	}
+	if status.Launch != nil {
+		response["targetIP"] = status.Launch.TargetIP
+		response["params"] = status.Launch.Params
+		response["startedAt"] = status.Launch.StartedAt
+	}
+	addJobStatus(response, currentAttackJob(attack, podName))
+	addAttackReport(clientset, response, currentAttackJob(attack, podName))
+	response["podJobs"] = podLeaseHolders(podName)
+	return response, nil
+}
+
+// addAttackReport adds the attack's own measurements of the job to a status
+// response. A failing report is returned as reportError rather than failing
+// the status request.
+func addAttackReport(clientset *kubernetes.Clientset, response gin.H, job *attackJob) {
+	if job == nil || job.Attack.Report == nil {
+		return
+	}
+	report, err := job.Attack.Report(clientset, job)
+	if err != nil {
+		consoleLog("[%s] Failed to build report for job %s: %v\n", job.Attack.LogTag, job.ID, err)
+		response["reportError"] = err.Error()
+		return
+	}
+	response["report"] = report
+}
+
+// bindAttackPods binds the request and resolves the pods it addresses,
+// answering 400 when either fails
+func bindAttackPods(c *gin.Context, clientset *kubernetes.Clientset, req *AttackRequest) ([]string, bool) {
+	if err := bindAttackRequest(c, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		return nil, false
+	}
+	pods, err := resolveAttackPods(clientset, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return pods, true
+}
+
+// countStatus counts the fan-out results carrying the given status
+func countStatus(results []gin.H, status string) int {
+	count := 0
+	for _, result := range results {
+		if result["status"] == status {
+			count++
+		}
+	}
+	return count
+}
+
+func runAttack(c *gin.Context, clientset *kubernetes.Clientset, attack *Attack) {
+	var req AttackRequest
+	pods, ok := bindAttackPods(c, clientset, &req)
+	if !ok {
+		return
+	}
+	if err := attack.normalize(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !req.fanOut() {
+		response, err := launchAttackOnPod(clientset, attack, req)
+		if err != nil {
+			respondAttackError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	consoleLog("[%s] Launching %s from %d pods\n", attack.LogTag, attack.Name, len(pods))
+	results := fanOutPods(pods, req.Concurrency, func(pod string) gin.H {
+		podReq := req
+		podReq.PodName = pod
+		response, err := launchAttackOnPod(clientset, attack, podReq)
+		if err != nil {
+			return failedPodResult(err)
+		}
+		response["status"] = "started"
+		return response
+	})
+	started := countStatus(results, "started")
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("%s started from %d of %d pods", attack.Name, started, len(pods)),
+		"total":   len(pods),
+		"started": started,
+		"failed":  len(pods) - started,
+		"results": results,
+	})
+}
+
+func stopAttackHandler(c *gin.Context, clientset *kubernetes.Clientset, attack *Attack) {
+	var req AttackRequest
+	pods, ok := bindAttackPods(c, clientset, &req)
+	if !ok {
+		return
+	}
+
+	if !req.fanOut() {
+		c.JSON(http.StatusOK, stopAttackOnPod(clientset, attack, req.PodName))
+		return
+	}
+
+	results := fanOutPods(pods, req.Concurrency, func(pod string) gin.H {
+		response := stopAttackOnPod(clientset, attack, pod)
+		response["status"] = "stopped"
+		return response
+	})
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("%s stopped in %d pods", attack.Name, len(pods)),
+		"total":   len(pods),
+		"results": results,
+	})
+}
+
+func checkAttackStatusHandler(c *gin.Context, clientset *kubernetes.Clientset, attack *Attack) {
+	var req AttackRequest
+	pods, ok := bindAttackPods(c, clientset, &req)
+	if !ok {
+		return
+	}
+
+	if !req.fanOut() {
+		response, err := attackStatusOnPod(clientset, attack, req.PodName)
+		if err != nil {
+			respondAttackError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	results := fanOutPods(pods, req.Concurrency, func(pod string) gin.H {
+		response, err := attackStatusOnPod(clientset, attack, pod)
+		if err != nil {
+			return failedPodResult(err)
+		}
+		return response
+	})
+	running := countStatus(results, "running")
+	status := "not running"
+	if running > 0 {
+		status = "running"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":  status,
+		"total":   len(pods),
+		"running": running,
+		"failed":  countStatus(results, "failed"),
+		"results": results,
+	})
+}
+
+// attackHandler binds one of the generic attack handlers to a fixed attack
+// type, for the legacy per-attack routes
+func attackHandler(clientset *kubernetes.Clientset, attackType string, handle func(*gin.Context, *kubernetes.Clientset, *Attack)) gin.HandlerFunc {
+	attack, ok := LookupAttack(attackType)
+	if !ok {
+		panic(fmt.Sprintf("attack type %q is not registered", attackType))
+	}
+	return func(c *gin.Context) {
+		handle(c, clientset, attack)
+	}
+}
+
+// ListAttacks returns every registered attack type
+func ListAttacks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var attacks []gin.H
+		for _, attack := range registeredAttacks() {
+			entry := gin.H{
+				"type":           attack.Type,
+				"name":           attack.Name,
+				"label":          attack.Label,
+				"targetRequired": attack.TargetRequired,
+				"params":         attack.Params,
+				"defaults":       attack.Defaults,
+			}
+			if len(attack.Modes) > 0 {
+				entry["modes"] = attack.Modes
+			}
+			attacks = append(attacks, entry)
+		}
+		c.JSON(http.StatusOK, gin.H{"attacks": attacks})
+	}
+}
+
+// RunAttack launches the attack named by the :type route parameter
+func RunAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if attack, ok := attackFromParam(c); ok {
+			runAttack(c, clientset, attack)
+		}
+	}
+}
+
+// StopAttack stops the attack named by the :type route parameter
+func StopAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if attack, ok := attackFromParam(c); ok {
+			stopAttackHandler(c, clientset, attack)
+		}
+	}
+}
+
+// CheckAttackStatus checks whether the attack named by the :type route parameter is running
+func CheckAttackStatus(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if attack, ok := attackFromParam(c); ok {
+			checkAttackStatusHandler(c, clientset, attack)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"k8s-status-api/k8s"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultFanOutConcurrency bounds how many pods are handled at once
+	defaultFanOutConcurrency = 8
+	// maxFanOutConcurrency is the highest concurrency a request may ask for
+	maxFanOutConcurrency = 32
+)
+
+// fanOut reports whether the request addresses several pods rather than a
+// single podName
+func (r *AttackRequest) fanOut() bool {
+	return len(r.Pods) > 0 || r.Selector != "" || r.PodPattern != ""
+}
+
+// resolveAttackPods returns the pods a request addresses, in a stable order
+// and without duplicates
+func resolveAttackPods(clientset *kubernetes.Clientset, req *AttackRequest) ([]string, error) {
+	if !req.fanOut() {
+		if req.PodName == "" {
+			return nil, fmt.Errorf("podName, pods, selector or podPattern is required")
+		}
+		if err := validatePodName("podName", req.PodName); err != nil {
+			return nil, err
+		}
+		return []string{req.PodName}, nil
+	}
+	if req.Concurrency < 0 || req.Concurrency > maxFanOutConcurrency {
+		return nil, fmt.Errorf("concurrency must be between 0 and %d", maxFanOutConcurrency)
+	}
+
+	if req.PodName != "" {
+		if err := validatePodName("podName", req.PodName); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range req.Pods {
+		if err := validatePodName("pods", name); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	var pods []string
+	add := func(names ...string) {
+		for _, name := range names {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				pods = append(pods, name)
+			}
+		}
+	}
+	add(req.PodName)
+	add(req.Pods...)
+
+	if req.Selector != "" || req.PodPattern != "" {
+		selected, err := k8s.SelectPods(context.Background(), clientset, k8s.DefaultNamespace, req.Selector, req.PodPattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select pods: %v", err)
+		}
+		add(selected...)
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("no running pods match the request")
+	}
+	return pods, nil
+}
+
+// fanOutPods runs handle for every pod with at most concurrency calls in
+// flight and returns the results in pod order
+func fanOutPods(pods []string, concurrency int, handle func(pod string) gin.H) []gin.H {
+	if concurrency <= 0 {
+		concurrency = defaultFanOutConcurrency
+	}
+	results := make([]gin.H, len(pods))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, pod := range pods {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, pod string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			result := handle(pod)
+			result["pod"] = pod
+			results[i] = result
+		}(i, pod)
+	}
+	wg.Wait()
+	return results
+}
+
+// failedPodResult describes a per-pod failure in a fan-out response
+func failedPodResult(err error) gin.H {
+	var ae *attackError
+	if errors.As(err, &ae) {
+		return gin.H{"status": "failed", "error": ae.Message, "details": errorDetails(ae.Err)}
+	}
+	return gin.H{"status": "failed", "error": err.Error()}
+}
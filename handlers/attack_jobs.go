@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// attackPollInterval is how often a running job checks that its process is alive
+const attackPollInterval = 5 * time.Second
+
+// Reasons an attack job ended
+const (
+	exitCompleted = "completed" // Deadline reached or the script finished its own duration
+	exitStopped   = "stopped"   // Stopped through the API
+	exitCrashed   = "crashed"   // Process exited before it was due to
+)
+
+// attackJob tracks one launched attack until it ends
+type attackJob struct {
+	ID         string
+	Attack     *Attack
+	PodName    string
+	Container  string // Ephemeral container running the attack, empty for the pod's default container
+	PID        string
+	LogFile    string // Output of the attack script inside the pod
+	MonitorPod string // Pod whose interfaces are sampled alongside the attacker's, e.g. the UPF
+	TargetIP   string
+	Params     AttackParams
+	StartedAt  time.Time
+	Deadline   time.Time // Zero when the attack runs until stopped
+	EndedAt    time.Time
+	ExitReason string
+
+	AllowConcurrent bool // The job shares its pod with other attacks that allow it
+
+	throughput *throughputMonitor // Interface rates measured while the job runs
+	done       chan struct{}      // Closed when the job ends
+}
+
+// attackJobs holds the most recent job per attack type and pod
+var attackJobs = make(map[string]*attackJob)
+var attackJobsMutex sync.Mutex
+
+func attackJobKey(attack *Attack, podName string) string {
+	return attack.Type + "/" + podName
+}
+
+// newAttackJobID returns a job ID that is unique for the lifetime of the process
+func newAttackJobID(attack *Attack, startedAt time.Time) string {
+	return attack.Type + "-" + strconv.FormatInt(startedAt.UnixNano(), 36)
+}
+
+// remaining returns the time left before the deadline, or zero
+func (j *attackJob) remaining() time.Duration {
+	if j.Deadline.IsZero() || !j.EndedAt.IsZero() {
+		return 0
+	}
+	if left := time.Until(j.Deadline); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// snapshot returns a copy of the job that is safe to read without the lock
+func (j *attackJob) snapshot() attackJob {
+	attackJobsMutex.Lock()
+	defer attackJobsMutex.Unlock()
+	return *j
+}
+
+// newAttackJob prepares the job of an attack about to be launched, so that its
+// ID and log file are known to the pod lease and the launcher
+func newAttackJob(attack *Attack, req AttackRequest) *attackJob {
+	id := newAttackJobID(attack, time.Now())
+	return &attackJob{
+		ID:              id,
+		Attack:          attack,
+		PodName:         req.PodName,
+		LogFile:         attack.logFile(id),
+		MonitorPod:      req.MonitorPod,
+		TargetIP:        req.TargetIP,
+		Params:          req.Params,
+		AllowConcurrent: req.AllowConcurrent,
+		throughput:      &throughputMonitor{},
+		done:            make(chan struct{}),
+	}
+}
+
+// trackAttackJob records a freshly launched attack and starts watching it
+func trackAttackJob(clientset *kubernetes.Clientset, job *attackJob, pid string, durationSeconds int) {
+	now := time.Now()
+	job.PID = pid
+	job.StartedAt = now
+	if durationSeconds > 0 {
+		job.Deadline = now.Add(time.Duration(durationSeconds) * time.Second)
+	}
+
+	attackJobsMutex.Lock()
+	key := attackJobKey(job.Attack, job.PodName)
+	if previous := attackJobs[key]; previous != nil && previous.EndedAt.IsZero() {
+		// The pod's lease keeps a second job of the attack out, so this is a bug
+		consoleLog("[%s] Job %s is still running in pod %s while job %s starts\n", job.Attack.LogTag, previous.ID, job.PodName, job.ID)
+	}
+	attackJobs[key] = job
+	attackJobsMutex.Unlock()
+
+	putJob(attackJobRecord(job))
+	recordAttackStart(clientset, job)
+	go watchAttackJob(clientset, job)
+	go monitorThroughput(clientset, job)
+}
+
+// currentAttackJob returns the most recent job for the attack and pod
+func currentAttackJob(attack *Attack, podName string) *attackJob {
+	attackJobsMutex.Lock()
+	defer attackJobsMutex.Unlock()
+	return attackJobs[attackJobKey(attack, podName)]
+}
+
+// finishAttackJob records why a job ended and frees its pod. Only the first
+// reason is kept.
+func finishAttackJob(job *attackJob, reason string) bool {
+	attackJobsMutex.Lock()
+	if !job.EndedAt.IsZero() {
+		attackJobsMutex.Unlock()
+		return false
+	}
+	job.EndedAt = time.Now()
+	job.ExitReason = reason
+	close(job.done)
+	attackJobsMutex.Unlock()
+
+	releasePodLease(job.PodName, job.ID)
+	endJob(job.ID, reason, job.EndedAt)
+	recordAttackEnd(job.ID, job.EndedAt, reason)
+	return true
+}
+
+// watchAttackJob stops the attack when its deadline passes and notices when
+// the process exits on its own. It runs independently of the HTTP request that
+// launched the attack.
+func watchAttackJob(clientset *kubernetes.Clientset, job *attackJob) {
+	tag := job.Attack.LogTag
+	ticker := time.NewTicker(attackPollInterval)
+	defer ticker.Stop()
+
+	var deadline <-chan time.Time
+	if !job.Deadline.IsZero() {
+		timer := time.NewTimer(time.Until(job.Deadline))
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		select {
+		case <-job.done:
+			return
+
+		case <-deadline:
+			unlock := lockPod(job.PodName)
+			if finishAttackJob(job, exitCompleted) {
+				consoleLog("[%s] Duration of job %s elapsed, stopping %s in pod %s\n", tag, job.ID, job.Attack.Name, job.PodName)
+				stopAttack(clientset, job.Attack, job.PodName, job.Container)
+			}
+			unlock()
+			return
+
+		case <-ticker.C:
+			alive, err := attackProcessAlive(clientset, job)
+			if err != nil {
+				// The pod may be briefly unreachable, try again on the next tick
+				consoleLog("[%s] Failed to check job %s: %v\n", tag, job.ID, err)
+				continue
+			}
+			if alive {
+				continue
+			}
+
+			reason := exitCrashed
+			if ranFullDuration(job) {
+				reason = exitCompleted
+			}
+			unlock := lockPod(job.PodName)
+			if finishAttackJob(job, reason) {
+				consoleLog("[%s] Job %s exited (%s), cleaning up pod %s\n", tag, job.ID, reason, job.PodName)
+				// Remove helper processes such as hping3 the script may have left behind
+				stopAttack(clientset, job.Attack, job.PodName, job.Container)
+			}
+			unlock()
+			return
+		}
+	}
+}
+
+// attackProcessAlive checks whether the job's process, or agent run, is still running
+func attackProcessAlive(clientset *kubernetes.Clientset, job *attackJob) (bool, error) {
+	if job.Attack.Agent {
+		return agentRunAlive(clientset, job.PodName, job.Container, job.ID)
+	}
+	command := []string{"pgrep", "-f", job.Attack.processPattern()}
+	if job.PID != "" {
+		command = []string{"ps", "-p", job.PID}
+	}
+	if _, err := containerExec(clientset, job.PodName, job.Container, command...); err != nil {
+		if noProcessFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// ranFullDuration reports whether a process that exited on its own had been
+// asked to stop after its duration parameter
+func ranFullDuration(job *attackJob) bool {
+	if job.Params.Duration <= 0 {
+		return false
+	}
+	// The script's clock starts a little after ours, allow one poll of slack
+	expected := time.Duration(job.Params.Duration)*time.Second - attackPollInterval
+	return time.Since(job.StartedAt) >= expected
+}
+
+// addJobStatus adds the job's timing and exit information to a status response
+func addJobStatus(response map[string]interface{}, job *attackJob) {
+	if job == nil {
+		return
+	}
+	j := job.snapshot()
+	response["jobId"] = j.ID
+	if !j.Deadline.IsZero() {
+		response["deadline"] = j.Deadline
+		response["remainingSeconds"] = int(j.remaining().Round(time.Second) / time.Second)
+	}
+	if !j.EndedAt.IsZero() {
+		response["exitReason"] = j.ExitReason
+		response["endedAt"] = j.EndedAt
+	}
+	if j.throughput != nil {
+		response["throughput"] = j.throughput.status()
+	}
+}
+
+// describeDeadline formats the deadline for log messages
+func describeDeadline(job *attackJob) string {
+	if job.Deadline.IsZero() {
+		return "no deadline"
+	}
+	return fmt.Sprintf("stopping at %s", job.Deadline.Format(time.RFC3339))
+}
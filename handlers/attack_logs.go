@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s-status-api/k8s"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// defaultLogTail is how many lines are returned when no tail is given
+	defaultLogTail = 100
+	// maxLogTail bounds the tail option
+	maxLogTail = 10000
+	// logDrainDelay lets tail -f pick up the last lines after a job ends
+	logDrainDelay = 2 * time.Second
+)
+
+// findAttackJob looks up a stored attack job by ID
+func findAttackJob(id string) (JobRecord, bool) {
+	records := listJobs(func(r *JobRecord) bool { return r.Kind == jobKindAttack && r.ID == id })
+	if len(records) == 0 {
+		return JobRecord{}, false
+	}
+	return records[0], true
+}
+
+// AttackLogs returns the output of an attack job. It serves both
+// /attacks/:type/logs, where gin's single wildcard name under /attacks makes
+// :type hold the job ID, and /jobs/:id/logs. By default the last tail lines
+// are returned as JSON; follow=true streams new lines as Server-Sent Events
+// until the job ends and download=true returns the whole log of an ended job
+// as a file.
+func AttackLogs(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if id == "" {
+			id = c.Param("type")
+		}
+		record, ok := findAttackJob(id)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Unknown attack job: %s", id)})
+			return
+		}
+		if record.LogFile == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No log was recorded for job %s", id)})
+			return
+		}
+
+		tail := defaultLogTail
+		if value := c.Query("tail"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > maxLogTail {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("tail must be between 0 and %d", maxLogTail)})
+				return
+			}
+			tail = n
+		}
+		follow, _ := strconv.ParseBool(c.Query("follow"))
+		download, _ := strconv.ParseBool(c.Query("download"))
+
+		switch {
+		case download:
+			downloadAttackLog(c, clientset, record)
+		case follow:
+			followAttackLog(c, clientset, record, tail)
+		default:
+			tailAttackLog(c, clientset, record, tail)
+		}
+	}
+}
+
+// tailAttackLog returns the last lines of the job's log
+func tailAttackLog(c *gin.Context, clientset *kubernetes.Clientset, record JobRecord, tail int) {
+	result, err := containerExec(clientset, record.Pod, record.Container, "tail", "-n", strconv.Itoa(tail), record.LogFile)
+	if err != nil {
+		consoleLog("[LOGS] Failed to read log of job %s: %v\n", record.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read job log", "details": errorDetails(err)})
+		return
+	}
+	lines := []string{}
+	if output := strings.TrimSuffix(result.Stdout, "\n"); output != "" {
+		lines = strings.Split(output, "\n")
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"jobId": record.ID,
+		"pod":   record.Pod,
+		"state": record.State,
+		"lines": lines,
+	})
+}
+
+// downloadAttackLog returns the whole log of an ended job as an attachment
+func downloadAttackLog(c *gin.Context, clientset *kubernetes.Clientset, record JobRecord) {
+	if record.State == jobRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Job %s is still running, follow its log instead", record.ID)})
+		return
+	}
+	var buf bytes.Buffer
+	opts := k8s.ExecOptions{Container: record.Container, Timeout: defaultExecTimeout}
+	if err := k8s.CopyFromPod(context.Background(), clientset, record.Pod, opts, record.LogFile, &buf); err != nil {
+		consoleLog("[LOGS] Failed to download log of job %s: %v\n", record.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read job log", "details": errorDetails(err)})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", record.ID+".log"))
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+}
+
+// followAttackLog streams the job's log as Server-Sent Events: a "log" event
+// per line, then an "end" event carrying the job's final state. Streaming
+// stops when the job ends or the client disconnects.
+func followAttackLog(c *gin.Context, clientset *kubernetes.Clientset, record JobRecord, tail int) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	command := []string{"tail", "-n", strconv.Itoa(tail), record.LogFile}
+	if record.State == jobRunning {
+		command = []string{"tail", "-n", strconv.Itoa(tail), "-f", record.LogFile}
+		if attack, ok := LookupAttack(record.Type); ok {
+			if job := currentAttackJob(attack, record.Pod); job != nil && job.ID == record.ID {
+				go func() {
+					select {
+					case <-job.done:
+						time.Sleep(logDrainDelay)
+						cancel()
+					case <-ctx.Done():
+					}
+				}()
+			}
+		}
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	writer := &sseLineWriter{c: c}
+	_, err := k8s.Exec(ctx, clientset, record.Pod, k8s.ExecOptions{
+		Container: record.Container,
+		Command:   command,
+		Stdout:    writer,
+	})
+	writer.flushPartial()
+	if c.Request.Context().Err() != nil {
+		return // Client went away
+	}
+	if err != nil && ctx.Err() == nil {
+		c.SSEvent("error", gin.H{"error": "Failed to read job log", "details": errorDetails(err)})
+	}
+
+	state := record.State
+	if latest, ok := findAttackJob(record.ID); ok {
+		state = latest.State
+	}
+	c.SSEvent("end", gin.H{"jobId": record.ID, "state": state})
+	c.Writer.Flush()
+}
+
+// sseLineWriter sends every complete line written to it as a "log" event
+type sseLineWriter struct {
+	c       *gin.Context
+	partial []byte
+}
+
+func (w *sseLineWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.c.SSEvent("log", string(w.partial[:i]))
+		w.partial = w.partial[i+1:]
+	}
+	w.c.Writer.Flush()
+	return len(p), nil
+}
+
+// flushPartial sends a last line that had no trailing newline
+func (w *sseLineWriter) flushPartial() {
+	if len(w.partial) > 0 {
+		w.c.SSEvent("log", string(w.partial))
+		w.partial = nil
+	}
+}
@@ -0,0 +1,377 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Names of the tunable attack parameters, as used in Attack.Params and in
+// validation messages
+const (
+	ParamPacketRate   = "packetRate"
+	ParamPayloadSize  = "payloadSize"
+	ParamDuration     = "duration"
+	ParamThreads      = "threads"
+	ParamSrcPortRange = "srcPortRange"
+	ParamTEIDRange    = "teidRange"
+	ParamSEIDRange    = "seidRange"
+	ParamUECount      = "ueCount"
+	ParamRegRate      = "registrationRate"
+	ParamHoldSeconds  = "holdSeconds"
+	ParamMode         = "mode"
+	ParamDstPort      = "dstPort"
+	ParamConnections  = "connections"
+	ParamBurstSeconds = "burstSeconds"
+	ParamPauseSeconds = "pauseSeconds"
+)
+
+// Limits applied to every attack regardless of what its script accepts
+const (
+	maxPacketRate  = 1000000
+	maxPayloadSize = 65000
+	maxDuration    = 24 * 60 * 60
+	maxThreads     = 64
+	maxUECount     = 1000
+	maxRegRate     = 1000
+	maxConnections = 10000
+)
+
+// AttackParams are the tunable settings of an attack. They reach the script
+// as environment variables, zero values leave the script's own default.
+type AttackParams struct {
+	PacketRate   int    `json:"packetRate,omitempty"`   // Packets per second, 0 sends as fast as possible
+	PayloadSize  int    `json:"payloadSize,omitempty"`  // Payload bytes per packet
+	Duration     int    `json:"duration,omitempty"`     // Seconds before the script exits on its own
+	Threads      int    `json:"threads,omitempty"`      // Number of sending threads
+	SrcPortRange string `json:"srcPortRange,omitempty"` // Source ports as "min-max" or a single port
+	TEIDRange    string `json:"teidRange,omitempty"`    // GTP-U TEIDs as "min-max" or a single TEID, decimal or 0x hex
+	SEIDRange    string `json:"seidRange,omitempty"`    // PFCP SEIDs as "min-max" or a single SEID, decimal or 0x hex
+
+	UECount          int `json:"ueCount,omitempty"`          // UEs registering in each storm cycle
+	RegistrationRate int `json:"registrationRate,omitempty"` // Registrations started per second
+	HoldSeconds      int `json:"holdSeconds,omitempty"`      // Seconds the UEs stay registered in each cycle
+
+	Mode         string `json:"mode,omitempty"`         // Variant of an attack with modes, e.g. the DDoS "syn" flood
+	DstPort      int    `json:"dstPort,omitempty"`      // Destination port of TCP and UDP floods
+	Connections  int    `json:"connections,omitempty"`  // Connections held open by low-and-slow attacks
+	BurstSeconds int    `json:"burstSeconds,omitempty"` // Length of each burst of a pulsing attack
+	PauseSeconds int    `json:"pauseSeconds,omitempty"` // Silence between the bursts of a pulsing attack
+}
+
+// attackMode is one variant of an attack with its own parameters
+type attackMode struct {
+	Params   []string     `json:"params"`   // Names of the AttackParams the mode honours besides mode itself
+	Defaults AttackParams `json:"defaults"` // Values applied to parameters the request leaves unset
+}
+
+// set returns the names of the parameters that carry a value
+func (p AttackParams) set() []string {
+	var names []string
+	if p.PacketRate != 0 {
+		names = append(names, ParamPacketRate)
+	}
+	if p.PayloadSize != 0 {
+		names = append(names, ParamPayloadSize)
+	}
+	if p.Duration != 0 {
+		names = append(names, ParamDuration)
+	}
+	if p.Threads != 0 {
+		names = append(names, ParamThreads)
+	}
+	if p.SrcPortRange != "" {
+		names = append(names, ParamSrcPortRange)
+	}
+	if p.TEIDRange != "" {
+		names = append(names, ParamTEIDRange)
+	}
+	if p.SEIDRange != "" {
+		names = append(names, ParamSEIDRange)
+	}
+	if p.UECount != 0 {
+		names = append(names, ParamUECount)
+	}
+	if p.RegistrationRate != 0 {
+		names = append(names, ParamRegRate)
+	}
+	if p.HoldSeconds != 0 {
+		names = append(names, ParamHoldSeconds)
+	}
+	if p.Mode != "" {
+		names = append(names, ParamMode)
+	}
+	if p.DstPort != 0 {
+		names = append(names, ParamDstPort)
+	}
+	if p.Connections != 0 {
+		names = append(names, ParamConnections)
+	}
+	if p.BurstSeconds != 0 {
+		names = append(names, ParamBurstSeconds)
+	}
+	if p.PauseSeconds != 0 {
+		names = append(names, ParamPauseSeconds)
+	}
+	return names
+}
+
+// withDefaults fills the unset parameters from defaults
+func (p AttackParams) withDefaults(defaults AttackParams) AttackParams {
+	if p.PacketRate == 0 {
+		p.PacketRate = defaults.PacketRate
+	}
+	if p.PayloadSize == 0 {
+		p.PayloadSize = defaults.PayloadSize
+	}
+	if p.Duration == 0 {
+		p.Duration = defaults.Duration
+	}
+	if p.Threads == 0 {
+		p.Threads = defaults.Threads
+	}
+	if p.SrcPortRange == "" {
+		p.SrcPortRange = defaults.SrcPortRange
+	}
+	if p.TEIDRange == "" {
+		p.TEIDRange = defaults.TEIDRange
+	}
+	if p.SEIDRange == "" {
+		p.SEIDRange = defaults.SEIDRange
+	}
+	if p.UECount == 0 {
+		p.UECount = defaults.UECount
+	}
+	if p.RegistrationRate == 0 {
+		p.RegistrationRate = defaults.RegistrationRate
+	}
+	if p.HoldSeconds == 0 {
+		p.HoldSeconds = defaults.HoldSeconds
+	}
+	if p.Mode == "" {
+		p.Mode = defaults.Mode
+	}
+	if p.DstPort == 0 {
+		p.DstPort = defaults.DstPort
+	}
+	if p.Connections == 0 {
+		p.Connections = defaults.Connections
+	}
+	if p.BurstSeconds == 0 {
+		p.BurstSeconds = defaults.BurstSeconds
+	}
+	if p.PauseSeconds == 0 {
+		p.PauseSeconds = defaults.PauseSeconds
+	}
+	return p
+}
+
+// paramsFor returns the parameter names the request may set, which for an
+// attack with modes depend on the requested mode
+func (a *Attack) paramsFor(p AttackParams) ([]string, error) {
+	if len(a.Modes) == 0 {
+		return a.Params, nil
+	}
+	name := p.Mode
+	if name == "" {
+		name = a.Defaults.Mode
+	}
+	mode, ok := a.Modes[name]
+	if !ok {
+		return nil, fmt.Errorf("%s has no mode %q (modes: %s)", a.Name, name, strings.Join(a.modeNames(), ", "))
+	}
+	return append([]string{ParamMode}, mode.Params...), nil
+}
+
+// modeNames returns the attack's modes in a stable order
+func (a *Attack) modeNames() []string {
+	names := make([]string, 0, len(a.Modes))
+	for name := range a.Modes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// withAttackDefaults fills the unset parameters from the defaults of the
+// requested mode, then from the attack's
+func (a *Attack) withAttackDefaults(p AttackParams) AttackParams {
+	if p.Mode == "" {
+		p.Mode = a.Defaults.Mode
+	}
+	if mode, ok := a.Modes[p.Mode]; ok {
+		p = p.withDefaults(mode.Defaults)
+	}
+	return p.withDefaults(a.Defaults)
+}
+
+// validateParams rejects parameters the attack's script does not honour and
+// values outside the allowed ranges
+func (a *Attack) validateParams(p AttackParams) error {
+	params, err := a.paramsFor(p)
+	if err != nil {
+		return err
+	}
+	supported := make(map[string]bool, len(params))
+	for _, name := range params {
+		supported[name] = true
+	}
+	for _, name := range p.set() {
+		if !supported[name] {
+			subject := a.Name
+			if p.Mode != "" {
+				subject = fmt.Sprintf("%s mode %s", a.Name, p.Mode)
+			}
+			return fmt.Errorf("%s does not support the %s parameter (supported: %s)",
+				subject, name, strings.Join(params, ", "))
+		}
+	}
+
+	if p.PacketRate < 0 || p.PacketRate > maxPacketRate {
+		return fmt.Errorf("packetRate must be between 0 and %d", maxPacketRate)
+	}
+	if p.PayloadSize < 0 || p.PayloadSize > maxPayloadSize {
+		return fmt.Errorf("payloadSize must be between 0 and %d", maxPayloadSize)
+	}
+	if p.Duration < 0 || p.Duration > maxDuration {
+		return fmt.Errorf("duration must be between 0 and %d seconds", maxDuration)
+	}
+	if p.Threads < 0 || p.Threads > maxThreads {
+		return fmt.Errorf("threads must be between 0 and %d", maxThreads)
+	}
+	if p.SrcPortRange != "" {
+		if _, _, err := parseRange(p.SrcPortRange, 1, 65535); err != nil {
+			return fmt.Errorf("invalid srcPortRange: %v", err)
+		}
+	}
+	if p.TEIDRange != "" {
+		if _, _, err := parseRange(p.TEIDRange, 0, 0xFFFFFFFF); err != nil {
+			return fmt.Errorf("invalid teidRange: %v", err)
+		}
+	}
+	if p.SEIDRange != "" {
+		if _, _, err := parseRange(p.SEIDRange, 0, math.MaxUint64); err != nil {
+			return fmt.Errorf("invalid seidRange: %v", err)
+		}
+	}
+	if p.UECount < 0 || p.UECount > maxUECount {
+		return fmt.Errorf("ueCount must be between 0 and %d", maxUECount)
+	}
+	if p.RegistrationRate < 0 || p.RegistrationRate > maxRegRate {
+		return fmt.Errorf("registrationRate must be between 0 and %d", maxRegRate)
+	}
+	if p.HoldSeconds < 0 || p.HoldSeconds > maxDuration {
+		return fmt.Errorf("holdSeconds must be between 0 and %d", maxDuration)
+	}
+	if p.DstPort < 0 || p.DstPort > 65535 {
+		return fmt.Errorf("dstPort must be between 0 and 65535 (0 uses the mode default)")
+	}
+	if p.Connections < 0 || p.Connections > maxConnections {
+		return fmt.Errorf("connections must be between 0 and %d", maxConnections)
+	}
+	if p.BurstSeconds < 0 || p.BurstSeconds > maxDuration {
+		return fmt.Errorf("burstSeconds must be between 0 and %d", maxDuration)
+	}
+	if p.PauseSeconds < 0 || p.PauseSeconds > maxDuration {
+		return fmt.Errorf("pauseSeconds must be between 0 and %d", maxDuration)
+	}
+	return nil
+}
+
+// parseRange parses "min-max" or a single value, each decimal or 0x hex,
+// and checks both ends against [lower, upper]
+func parseRange(value string, lower, upper uint64) (uint64, uint64, error) {
+	lo, hi, isRange := strings.Cut(value, "-")
+	if !isRange {
+		hi = lo
+	}
+	min, err := parseRangeValue(lo)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q is not a number", lo)
+	}
+	max, err := parseRangeValue(hi)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q is not a number", hi)
+	}
+	if min > max {
+		return 0, 0, fmt.Errorf("start %d is greater than end %d", min, max)
+	}
+	if min < lower || max > upper {
+		return 0, 0, fmt.Errorf("values must be between %d and %d", lower, upper)
+	}
+	return min, max, nil
+}
+
+// parseRangeValue parses one end of a range as decimal, or as hex with an
+// explicit 0x prefix. Leading zeros stay decimal rather than octal.
+func parseRangeValue(value string) (uint64, error) {
+	value = strings.TrimSpace(value)
+	if len(value) > 2 && (value[:2] == "0x" || value[:2] == "0X") {
+		return strconv.ParseUint(value[2:], 16, 64)
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+// scriptEnv returns the environment variables handed to the attack script
+func scriptEnv(targetIP string, p AttackParams) []string {
+	var env []string
+	if targetIP != "" {
+		env = append(env, "TARGET_IP="+targetIP)
+	}
+	if p.PacketRate != 0 {
+		env = append(env, fmt.Sprintf("PACKET_RATE=%d", p.PacketRate))
+	}
+	if p.PayloadSize != 0 {
+		env = append(env, fmt.Sprintf("PAYLOAD_SIZE=%d", p.PayloadSize))
+	}
+	if p.Duration != 0 {
+		env = append(env, fmt.Sprintf("DURATION=%d", p.Duration))
+	}
+	if p.Threads != 0 {
+		env = append(env, fmt.Sprintf("THREADS=%d", p.Threads))
+	}
+	if p.SrcPortRange != "" {
+		min, max, _ := parseRange(p.SrcPortRange, 1, 65535)
+		env = append(env, fmt.Sprintf("SRC_PORT_MIN=%d", min), fmt.Sprintf("SRC_PORT_MAX=%d", max))
+	}
+	if p.TEIDRange != "" {
+		min, max, _ := parseRange(p.TEIDRange, 0, 0xFFFFFFFF)
+		env = append(env, fmt.Sprintf("TEID_MIN=%d", min), fmt.Sprintf("TEID_MAX=%d", max))
+	}
+	if p.SEIDRange != "" {
+		min, max, _ := parseRange(p.SEIDRange, 0, math.MaxUint64)
+		env = append(env, fmt.Sprintf("SEID_MIN=%d", min), fmt.Sprintf("SEID_MAX=%d", max))
+	}
+	if p.UECount != 0 {
+		env = append(env, fmt.Sprintf("UE_COUNT=%d", p.UECount))
+	}
+	if p.RegistrationRate != 0 {
+		env = append(env, fmt.Sprintf("REGISTRATION_RATE=%d", p.RegistrationRate))
+	}
+	if p.HoldSeconds != 0 {
+		env = append(env, fmt.Sprintf("HOLD_SECONDS=%d", p.HoldSeconds))
+	}
+	if p.Mode != "" {
+		env = append(env, "MODE="+p.Mode)
+	}
+	if p.DstPort != 0 {
+		env = append(env, fmt.Sprintf("DST_PORT=%d", p.DstPort))
+	}
+	if p.Connections != 0 {
+		env = append(env, fmt.Sprintf("CONNECTIONS=%d", p.Connections))
+	}
+	if p.BurstSeconds != 0 {
+		env = append(env, fmt.Sprintf("BURST_SECONDS=%d", p.BurstSeconds))
+	}
+	if p.PauseSeconds != 0 {
+		env = append(env, fmt.Sprintf("PAUSE_SECONDS=%d", p.PauseSeconds))
+	}
+	return env
+}
+
+// shellQuote quotes a value for safe use in a generated shell script
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
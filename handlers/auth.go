@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s-status-api/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// identityKey is the gin context key of the caller's identity
+const identityKey = "identity"
+
+// authenticator verifies request tokens. Nil, every request is refused; only
+// an explicitly disabled authenticator serves requests as auth.Anonymous.
+var authenticator *auth.Authenticator
+
+// SetAuthenticator configures how request tokens are verified
+func SetAuthenticator(a *auth.Authenticator) {
+	authenticator = a
+}
+
+// requestToken returns the token from "Authorization: Bearer", X-API-Key or,
+// for GET requests such as EventSource log streams that cannot set headers,
+// the access_token query parameter
+func requestToken(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if c.Request.Method == http.MethodGet {
+		return c.Query("access_token")
+	}
+	return ""
+}
+
+// Authenticate resolves the caller of every request from its token and
+// answers 401 when the token is missing or invalid
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authenticator.Disabled() {
+			c.Set(identityKey, auth.Anonymous)
+			c.Next()
+			return
+		}
+
+		token := requestToken(c)
+		if token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+		identity, err := authenticator.Authenticate(token)
+		if err != nil {
+			consoleLog("[AUTH] Rejected credentials from %s for %s %s: %v\n", c.ClientIP(), c.Request.Method, c.Request.URL.Path, err)
+			c.Header("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			// Why a token was refused is only logged, callers learn nothing about it
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			return
+		}
+		c.Set(identityKey, identity)
+		c.Next()
+	}
+}
+
+// requestIdentity returns the caller set by Authenticate
+func requestIdentity(c *gin.Context) *auth.Identity {
+	if value, ok := c.Get(identityKey); ok {
+		if identity, ok := value.(*auth.Identity); ok {
+			return identity
+		}
+	}
+	return nil
+}
+
+// RequireRole answers 403 to callers whose role does not include role
+func RequireRole(role auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := requestIdentity(c)
+		if identity == nil || !identity.Role.Allows(role) {
+			caller := "unauthenticated caller"
+			if identity != nil {
+				caller = fmt.Sprintf("%s (%s)", identity.Subject, identity.Role)
+			}
+			consoleLog("[AUTH] Denied %s %s to %s\n", c.Request.Method, c.Request.URL.Path, caller)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "Forbidden",
+				"details": fmt.Sprintf("%s %s requires the %s role", c.Request.Method, c.FullPath(), role),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// WhoAmI returns the caller's identity
+func WhoAmI() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, requestIdentity(c))
+	}
+}
+
+// CORS lets the browsers of the listed origins call the API. Requests from
+// other origins get no CORS headers, so browsers refuse them.
+func CORS(origins []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[strings.TrimSuffix(origin, "/")] = true
+	}
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Origin")
+		if origin := c.GetHeader("Origin"); origin != "" && allowed[origin] {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, OPTIONS")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+		}
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BenignLabel is written for flows outside every recorded attack window
+const BenignLabel = "BENIGN"
+
+// flowFileSuffix ends the name of every CICFlowMeter output file
+const flowFileSuffix = "_Flow.csv"
+
+// Dataset export states
+const (
+	exportRunning   = "running"
+	exportCompleted = "completed"
+	exportFailed    = "failed"
+)
+
+// flowTimestampLayouts are the Timestamp formats written by the Python and
+// Java CICFlowMeter builds
+var flowTimestampLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.000000",
+	"02/01/2006 03:04:05 PM",
+	"02/01/2006 15:04:05",
+	"02/01/2006 15:04",
+}
+
+// DatasetExportRequest selects the flow files and matching rules of an export
+type DatasetExportRequest struct {
+	Files        []string `json:"files"`        // Flow CSVs to label, relative to FlowOutputDirectory; defaults to every *_Flow.csv in it
+	SlackSeconds int      `json:"slackSeconds"` // Widen every attack window by this many seconds on each side
+	SubLabels    bool     `json:"subLabels"`    // Append the attack mode to the label, e.g. "DDoS_SYN"
+	Timezone     string   `json:"timezone"`     // IANA zone the flow Timestamps were written in, e.g. "Europe/Berlin"; defaults to UTC
+}
+
+// datasetManifest summarises an export next to its labelled CSV
+type datasetManifest struct {
+	ID                 string         `json:"id"`
+	CreatedAt          time.Time      `json:"createdAt"`
+	Files              []string       `json:"files"`
+	Output             string         `json:"output"`
+	TotalFlows         int            `json:"totalFlows"`
+	ClassCounts        map[string]int `json:"classCounts"`
+	FirstFlow          *time.Time     `json:"firstFlow,omitempty"`
+	LastFlow           *time.Time     `json:"lastFlow,omitempty"`
+	UnparsedTimestamps int            `json:"unparsedTimestamps"` // Flows labelled BENIGN because their Timestamp could not be read
+	GroundTruthRecords int            `json:"groundTruthRecords"` // Attack windows considered
+	SlackSeconds       int            `json:"slackSeconds"`
+	SubLabels          bool           `json:"subLabels"`
+	Timezone           string         `json:"timezone"`
+}
+
+// datasetExport tracks a running or finished export
+type datasetExport struct {
+	ID        string           `json:"id"`
+	Status    string           `json:"status"`
+	StartedAt time.Time        `json:"startedAt"`
+	EndedAt   *time.Time       `json:"endedAt,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Manifest  *datasetManifest `json:"manifest,omitempty"`
+}
+
+// attackWindow is a ground truth record resolved for flow matching
+type attackWindow struct {
+	Label    string
+	UEIP     string
+	TargetIP string
+	Start    time.Time
+	End      time.Time
+}
+
+// matches reports whether a flow between src and dst starting at ts belongs
+// to the attack, in either direction
+func (w *attackWindow) matches(src, dst string, ts time.Time) bool {
+	if ts.Before(w.Start) || ts.After(w.End) {
+		return false
+	}
+	if src == w.UEIP {
+		return w.TargetIP == "" || dst == w.TargetIP
+	}
+	if dst == w.UEIP {
+		return w.TargetIP == "" || src == w.TargetIP
+	}
+	return false
+}
+
+var datasetDir = "datasets"
+var datasetExports = make(map[string]*datasetExport)
+var datasetExportsMutex sync.Mutex
+
+// SetDatasetDir sets the directory that receives dataset exports
+func SetDatasetDir(dir string) {
+	datasetDir = dir
+}
+
+// attackWindows turns the ground truth into labelled windows. Attacks that are
+// still running end now. With subLabels the attack mode is appended to the
+// label, so that the sub-classes of an attack can be told apart.
+func attackWindows(slack time.Duration, subLabels bool) []attackWindow {
+	now := time.Now()
+	var windows []attackWindow
+	for _, record := range queryGroundTruth(groundTruthFilter{}) {
+		if record.UEIP == "" {
+			consoleLog("[DATASET] Skipping ground truth %s: UE IP unknown\n", record.ID)
+			continue
+		}
+		label := record.AttackType
+		if attack, ok := LookupAttack(record.AttackType); ok && attack.Label != "" {
+			label = attack.Label
+		}
+		if subLabels && record.Mode != "" {
+			label += "_" + strings.ToUpper(strings.ReplaceAll(record.Mode, "-", "_"))
+		}
+		end := now
+		if record.EndedAt != nil {
+			end = *record.EndedAt
+		}
+		windows = append(windows, attackWindow{
+			Label:    label,
+			UEIP:     record.UEIP,
+			TargetIP: record.TargetIP,
+			Start:    record.StartedAt.Add(-slack),
+			End:      end.Add(slack),
+		})
+	}
+	return windows
+}
+
+// parseFlowTimestamp parses a CICFlowMeter Timestamp, which carries no zone,
+// in loc
+func parseFlowTimestamp(value string, loc *time.Location) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for _, layout := range flowTimestampLayouts {
+		if ts, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// labelFlow returns the label of the first window the flow falls into
+func labelFlow(windows []attackWindow, src, dst string, ts time.Time) string {
+	for i := range windows {
+		if windows[i].matches(src, dst, ts) {
+			return windows[i].Label
+		}
+	}
+	return BenignLabel
+}
+
+// flowFiles returns the requested flow files, or every *_Flow.csv in the
+// flow output directory. Requested files are named relative to that
+// directory and may not leave it, so an export only reads flow files.
+func flowFiles(requested []string) ([]string, error) {
+	if len(requested) > 0 {
+		files := make([]string, 0, len(requested))
+		for _, name := range requested {
+			if !filepath.IsLocal(name) || hasDotDot(name) {
+				return nil, fmt.Errorf("flow file %q must be a path inside %s", name, traceConfig.FlowOutputDirectory)
+			}
+			if !strings.HasSuffix(name, flowFileSuffix) {
+				return nil, fmt.Errorf("flow file %q is not a *%s file", name, flowFileSuffix)
+			}
+			file := filepath.Join(traceConfig.FlowOutputDirectory, name)
+			if _, err := os.Stat(file); err != nil {
+				return nil, fmt.Errorf("flow file %s: %v", name, err)
+			}
+			files = append(files, file)
+		}
+		return files, nil
+	}
+	files, err := filepath.Glob(filepath.Join(traceConfig.FlowOutputDirectory, "*"+flowFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no *%s files in %s", flowFileSuffix, traceConfig.FlowOutputDirectory)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// hasDotDot reports whether any segment of the path is ".."
+func hasDotDot(path string) bool {
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == filepath.Separator }) {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// columnIndex maps trimmed column names to their position
+func columnIndex(header []string) map[string]int {
+	index := make(map[string]int, len(header))
+	for i, column := range header {
+		index[strings.TrimSpace(column)] = i
+	}
+	return index
+}
+
+// labelFlowFile appends the labelled rows of one flow file to out. The first
+// file's header defines the output columns; later files are mapped by name.
+func labelFlowFile(path string, out *csv.Writer, columns *[]string, windows []attackWindow, loc *time.Location, manifest *datasetManifest) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read header of %s: %v", path, err)
+	}
+	index := columnIndex(header)
+	for _, required := range []string{"Src IP", "Dst IP", "Timestamp"} {
+		if _, ok := index[required]; !ok {
+			return fmt.Errorf("%s has no %q column", path, required)
+		}
+	}
+
+	if *columns == nil {
+		// CICFlowMeter may already emit a placeholder Label column, it is replaced
+		for _, column := range header {
+			if name := strings.TrimSpace(column); name != "Label" {
+				*columns = append(*columns, name)
+			}
+		}
+		if err := out.Write(append(append([]string{}, *columns...), "Label")); err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, len(*columns)+1)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		label := BenignLabel
+		if ts, ok := parseFlowTimestamp(field("Timestamp"), loc); ok {
+			label = labelFlow(windows, field("Src IP"), field("Dst IP"), ts)
+			if manifest.FirstFlow == nil || ts.Before(*manifest.FirstFlow) {
+				manifest.FirstFlow = &ts
+			}
+			if manifest.LastFlow == nil || ts.After(*manifest.LastFlow) {
+				manifest.LastFlow = &ts
+			}
+		} else {
+			manifest.UnparsedTimestamps++
+		}
+
+		for i, column := range *columns {
+			row[i] = field(column)
+		}
+		row[len(*columns)] = label
+		if err := out.Write(row); err != nil {
+			return err
+		}
+		manifest.TotalFlows++
+		manifest.ClassCounts[label]++
+	}
+}
+
+// runDatasetExport labels the flow files into <datasetDir>/<id>
+func runDatasetExport(export *datasetExport, files []string, slackSeconds int, subLabels bool, loc *time.Location) (*datasetManifest, error) {
+	dir := filepath.Join(datasetDir, export.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	windows := attackWindows(time.Duration(slackSeconds)*time.Second, subLabels)
+	manifest := &datasetManifest{
+		ID:                 export.ID,
+		CreatedAt:          export.StartedAt,
+		Files:              files,
+		Output:             filepath.Join(dir, "labelled_flows.csv"),
+		ClassCounts:        map[string]int{},
+		GroundTruthRecords: len(windows),
+		SlackSeconds:       slackSeconds,
+		SubLabels:          subLabels,
+		Timezone:           loc.String(),
+	}
+
+	output, err := os.Create(manifest.Output)
+	if err != nil {
+		return nil, err
+	}
+	defer output.Close()
+	writer := csv.NewWriter(output)
+
+	var columns []string
+	for _, file := range files {
+		consoleLog("[DATASET] %s: labelling %s\n", export.ID, file)
+		if err := labelFlowFile(file, writer, &columns, windows, loc, manifest); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), data, 0644); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// StartDatasetExport labels the generated flow files with the recorded attack
+// windows in the background
+func StartDatasetExport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req DatasetExportRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+				return
+			}
+		}
+		if req.SlackSeconds < 0 || req.SlackSeconds > maxDuration {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("slackSeconds must be between 0 and %d", maxDuration)})
+			return
+		}
+		if req.Timezone == "" {
+			req.Timezone = "UTC"
+		}
+		loc, err := time.LoadLocation(req.Timezone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown timezone %q", req.Timezone)})
+			return
+		}
+		files, err := flowFiles(req.Files)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		export := &datasetExport{
+			ID:        "export-" + strconv.FormatInt(now.UnixNano(), 36),
+			Status:    exportRunning,
+			StartedAt: now,
+		}
+		datasetExportsMutex.Lock()
+		datasetExports[export.ID] = export
+		datasetExportsMutex.Unlock()
+
+		go func() {
+			manifest, err := runDatasetExport(export, files, req.SlackSeconds, req.SubLabels, loc)
+			datasetExportsMutex.Lock()
+			defer datasetExportsMutex.Unlock()
+			ended := time.Now()
+			export.EndedAt = &ended
+			if err != nil {
+				consoleLog("[DATASET] Export %s failed: %v\n", export.ID, err)
+				export.Status = exportFailed
+				export.Error = err.Error()
+				return
+			}
+			consoleLog("[DATASET] Export %s wrote %d flows to %s\n", export.ID, manifest.TotalFlows, manifest.Output)
+			export.Status = exportCompleted
+			export.Manifest = manifest
+		}()
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": fmt.Sprintf("Export of %d flow files started", len(files)),
+			"id":      export.ID,
+			"files":   files,
+		})
+	}
+}
+
+// ListDatasetExports returns every export started since the backend came up
+func ListDatasetExports() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		datasetExportsMutex.Lock()
+		exports := make([]datasetExport, 0, len(datasetExports))
+		for _, export := range datasetExports {
+			exports = append(exports, *export)
+		}
+		datasetExportsMutex.Unlock()
+		sort.Slice(exports, func(i, j int) bool { return exports[i].StartedAt.After(exports[j].StartedAt) })
+		c.JSON(http.StatusOK, gin.H{"exports": exports})
+	}
+}
+
+// GetDatasetExport returns the status and manifest of one export
+func GetDatasetExport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		datasetExportsMutex.Lock()
+		export, ok := datasetExports[c.Param("id")]
+		var snapshot datasetExport
+		if ok {
+			snapshot = *export
+		}
+		datasetExportsMutex.Unlock()
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Unknown export: %s", c.Param("id"))})
+			return
+		}
+		c.JSON(http.StatusOK, snapshot)
+	}
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s-status-api/k8s"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/kubernetes"
+)
+
+// emergencyPodPrefix names the pods an emergency stop sweeps, besides the pods
+// of running jobs
+const emergencyPodPrefix = "ueransim"
+
+// EmergencyStopRequest is the optional payload of an emergency stop
+type EmergencyStopRequest struct {
+	StopTraceCollector bool `json:"stopTraceCollector" form:"stopTraceCollector"`
+	Concurrency        int  `json:"concurrency" form:"concurrency"` // Pods handled at once, defaults to defaultFanOutConcurrency
+}
+
+// emergencySweepScript builds the script that kills every known attack and
+// traffic process in a container and removes the attacks' PID files. It
+// prints "<type> <pid>" for each killed process and "pidfile <path>" for each
+// removed file. The script is read from stdin, so the patterns it looks for
+// never appear in its own command line.
+func emergencySweepScript() string {
+	var b strings.Builder
+	b.WriteString("kill_matching() {\n")
+	b.WriteString("\tfor pid in $(pgrep -f \"$2\"); do\n")
+	b.WriteString("\t\tkill -9 \"$pid\" 2>/dev/null && echo \"$1 $pid\"\n")
+	b.WriteString("\tdone\n")
+	b.WriteString("}\n")
+	for _, attack := range registeredAttacks() {
+		if attack.Agent {
+			continue
+		}
+		pidFile := shellQuote(attack.pidFile())
+		fmt.Fprintf(&b, "if [ -f %s ]; then\n", pidFile)
+		fmt.Fprintf(&b, "\tpid=$(cat %s)\n", pidFile)
+		fmt.Fprintf(&b, "\t[ -n \"$pid\" ] && kill -9 \"$pid\" 2>/dev/null && echo \"%s $pid\"\n", attack.Type)
+		fmt.Fprintf(&b, "\trm -f %s && echo \"pidfile %s\"\n", pidFile, attack.pidFile())
+		b.WriteString("fi\n")
+		for _, pattern := range append([]string{attack.processPattern()}, attack.ExtraProcesses...) {
+			fmt.Fprintf(&b, "kill_matching %s %s\n", attack.Type, shellQuote(pattern))
+		}
+	}
+	fmt.Fprintf(&b, "kill_matching %s %s\n", TrafficPhase, shellQuote(trafficScriptPattern))
+	b.WriteString("exit 0\n")
+	return b.String()
+}
+
+// sweepContainer runs the emergency sweep in one container of the pod, adding
+// the killed PIDs per attack type and the removed PID files to the report
+func sweepContainer(clientset *kubernetes.Clientset, podName, container string, killed map[string][]string, pidFiles *[]string) error {
+	result, err := k8s.Exec(context.Background(), clientset, podName, k8s.ExecOptions{
+		Container: container,
+		Command:   []string{"bash", "-s"},
+		Stdin:     strings.NewReader(emergencySweepScript()),
+		Timeout:   defaultExecTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(result.Stdout, "\n") {
+		kind, value, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		if kind == "pidfile" {
+			*pidFiles = append(*pidFiles, value)
+			continue
+		}
+		killed[kind] = appendUnique(killed[kind], value)
+	}
+	return nil
+}
+
+// appendUnique appends value unless the slice already holds it
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// endPodJobs marks every running job in the pod as stopped, so that their
+// watchers do not report the kills as crashes, and returns their IDs
+func endPodJobs(podName string) []string {
+	ended := []string{}
+	for _, attack := range registeredAttacks() {
+		if job := currentAttackJob(attack, podName); job != nil && finishAttackJob(job, exitStopped) {
+			ended = append(ended, job.ID)
+		}
+	}
+
+	// Jobs the store still holds, e.g. traffic tests or attacks launched
+	// before a restart that were not resumed
+	now := time.Now()
+	for _, record := range listJobs(func(r *JobRecord) bool { return r.Pod == podName && r.State == jobRunning }) {
+		endJob(record.ID, exitStopped, now)
+		releasePodLease(podName, record.ID)
+		if record.Kind == jobKindAttack {
+			recordAttackEnd(record.ID, now, exitStopped)
+		}
+		ended = append(ended, record.ID)
+	}
+	return ended
+}
+
+// podContainers returns the containers jobs ran in within the pod: the default
+// container and any ephemeral container an attack was launched in
+func podContainers(podName string) []string {
+	containers := []string{""}
+	for _, record := range listJobs(func(r *JobRecord) bool { return r.Pod == podName && r.Container != "" }) {
+		containers = appendUnique(containers, record.Container)
+	}
+	return containers
+}
+
+// emergencyStopPod ends every job in the pod and kills every attack and
+// traffic process in its containers
+func emergencyStopPod(clientset *kubernetes.Clientset, podName string) gin.H {
+	unlock := lockPod(podName)
+	defer unlock()
+	consoleLog("[EMERGENCY] Stopping everything in pod: %s\n", podName)
+
+	jobs := endPodJobs(podName)
+	// Launches still preparing the pod hold leases but no job yet
+	for _, jobID := range revokePodLeases(podName) {
+		jobs = appendUnique(jobs, jobID)
+	}
+	killed := make(map[string][]string)
+	pidFiles := []string{}
+	var failures []gin.H
+	for _, container := range podContainers(podName) {
+		if err := sweepContainer(clientset, podName, container, killed, &pidFiles); err != nil {
+			consoleLog("[ERROR] Emergency stop failed in pod %s: %v\n", podName, err)
+			failures = append(failures, gin.H{"container": container, "error": err.Error(), "details": errorDetails(err)})
+			continue
+		}
+		// Agent runs are stopped through the agent rather than killed
+		for _, attack := range registeredAttacks() {
+			if attack.Agent {
+				killed[attack.Type] = append(killed[attack.Type], stopAgentAttack(clientset, attack, podName, container)...)
+			}
+		}
+	}
+	for attackType, pids := range killed {
+		if len(pids) == 0 {
+			delete(killed, attackType)
+		}
+	}
+
+	result := gin.H{
+		"status":   "stopped",
+		"jobs":     jobs,
+		"killed":   killed,
+		"pidFiles": pidFiles,
+	}
+	if len(failures) > 0 {
+		result["status"] = "failed"
+		result["errors"] = failures
+	}
+	return result
+}
+
+// emergencyStopPods returns the running UERANSIM pods of the default
+// namespace and every pod a job is still recorded as running in
+func emergencyStopPods(clientset *kubernetes.Clientset) ([]string, error) {
+	var pods []string
+	add := func(name string) {
+		for _, pod := range pods {
+			if pod == name {
+				return
+			}
+		}
+		pods = append(pods, name)
+	}
+
+	// Attacks and traffic tests only ever run in the default namespace, which
+	// is also the one the sweep execs into
+	podList, err := k8s.GetPodsByPrefix(clientset, emergencyPodPrefix)
+	for _, pod := range podList {
+		if pod["status"] == "Running" && pod["namespace"] == k8s.DefaultNamespace {
+			add(pod["name"].(string))
+		}
+	}
+	for _, record := range listJobs(func(r *JobRecord) bool { return r.State == jobRunning }) {
+		add(record.Pod)
+	}
+	podLeasesMutex.Lock()
+	for pod := range podLeases {
+		add(pod)
+	}
+	podLeasesMutex.Unlock()
+
+	sort.Strings(pods)
+	return pods, err
+}
+
+// EmergencyStop stops every running scenario, attack and traffic test in all
+// UERANSIM pods and reports per pod what was killed
+func EmergencyStop(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req EmergencyStopRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters", "details": err.Error()})
+				return
+			}
+		} else if err := c.ShouldBindQuery(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters", "details": err.Error()})
+			return
+		}
+		if req.Concurrency < 0 || req.Concurrency > maxFanOutConcurrency {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("concurrency must be between 0 and %d", maxFanOutConcurrency)})
+			return
+		}
+		consoleLog("[EMERGENCY] Emergency stop requested\n")
+
+		// Scenarios go first so that they launch no further phases
+		scenarioIDs := stopAllScenarios()
+
+		pods, listErr := emergencyStopPods(clientset)
+		if listErr != nil {
+			// Still sweep the pods known from running jobs
+			consoleLog("[ERROR] Error listing %s pods: %v\n", emergencyPodPrefix, listErr)
+		}
+		results := fanOutPods(pods, req.Concurrency, func(pod string) gin.H {
+			return emergencyStopPod(clientset, pod)
+		})
+
+		failed := countStatus(results, "failed")
+		response := gin.H{
+			"message":   fmt.Sprintf("Emergency stop swept %d pods, %d failed", len(pods), failed),
+			"total":     len(pods),
+			"failed":    failed,
+			"scenarios": scenarioIDs,
+			"results":   results,
+		}
+		if listErr != nil {
+			response["error"] = "Failed to list pods"
+			response["details"] = listErr.Error()
+		}
+		if req.StopTraceCollector {
+			if stopTraceCollector() {
+				response["traceCollector"] = "stopped"
+			} else {
+				response["traceCollector"] = "not running"
+			}
+		}
+		consoleLog("[EMERGENCY] Emergency stop finished: %d pods, %d failed\n", len(pods), failed)
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"k8s-status-api/k8s"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ephemeralPrefix names the ephemeral containers attacks run in
+	ephemeralPrefix = "attack-tools"
+	// ephemeralStartTimeout bounds the image pull and start of the container
+	ephemeralStartTimeout = 5 * time.Minute
+)
+
+// ephemeralCapabilities lets the attack scripts open raw sockets in the pod's
+// network namespace
+var ephemeralCapabilities = []string{"NET_ADMIN", "NET_RAW"}
+
+// ephemeralImage is the tools image for attacks run with "ephemeral": true.
+// Empty disables ephemeral runs.
+var ephemeralImage string
+
+// SetEphemeralImage configures the image of the attack tools container
+func SetEphemeralImage(image string) {
+	ephemeralImage = image
+}
+
+// attackContainer returns the ephemeral container the attack should run in,
+// adding it to the pod when needed, or an empty string for the pod's default
+// container
+func attackContainer(clientset *kubernetes.Clientset, attack *Attack, req AttackRequest) (string, error) {
+	if !req.Ephemeral {
+		return "", nil
+	}
+	consoleLog("[%s] Ensuring ephemeral container with %s in pod: %s\n", attack.LogTag, ephemeralImage, req.PodName)
+	container, err := k8s.EnsureEphemeralContainer(context.Background(), clientset, req.PodName, k8s.EphemeralOptions{
+		NamePrefix:   ephemeralPrefix,
+		Image:        ephemeralImage,
+		Capabilities: ephemeralCapabilities,
+		StartTimeout: ephemeralStartTimeout,
+	})
+	if err != nil {
+		consoleLog("[ERROR] Error adding ephemeral container: %v\n", err)
+		return "", &attackError{"Failed to start ephemeral container", err}
+	}
+	return container, nil
+}
+
+// launchAttack starts the attack in one pod, in an ephemeral container when
+// requested, and starts tracking its job. The job leases the pod first, so a
+// launch conflicting with the pod's running jobs waits for them or fails. The
+// lease keeps conflicting launches out while the pod is prepared, so the pod's
+// lock is only taken for the launch itself and never waits on provisioning.
+func launchAttack(clientset *kubernetes.Clientset, attack *Attack, req AttackRequest) (*attackJob, error) {
+	job := newAttackJob(attack, req)
+	queue := time.Duration(req.QueueSeconds) * time.Second
+	if err := acquirePodLease(attack, req.PodName, job.ID, req.AllowConcurrent, queue); err != nil {
+		return nil, err
+	}
+
+	container, err := attackContainer(clientset, attack, req)
+	if err != nil {
+		releasePodLease(req.PodName, job.ID)
+		return nil, err
+	}
+	job.Container = container
+	if err := prepareAttack(clientset, attack, req, job); err != nil {
+		releasePodLease(req.PodName, job.ID)
+		return nil, err
+	}
+
+	unlock := lockPod(req.PodName)
+	defer unlock()
+	if !holdsPodLease(req.PodName, job.ID) {
+		consoleLog("[%s] Launch of job %s in pod %s was aborted by an emergency stop\n", attack.LogTag, job.ID, req.PodName)
+		return nil, &attackError{"Launch aborted", errors.New("an emergency stop revoked the pod's lease while it was being prepared")}
+	}
+	pid, err := execAttack(clientset, attack, req, job)
+	if err != nil {
+		releasePodLease(req.PodName, job.ID)
+		return nil, err
+	}
+	trackAttackJob(clientset, job, pid, req.DurationSeconds)
+	return job, nil
+}
+
+// attackContainerFor returns the container the attack was last launched in
+// for the pod, from the in-memory job or else the job store
+func attackContainerFor(attack *Attack, podName string) string {
+	if job := currentAttackJob(attack, podName); job != nil {
+		return job.Container
+	}
+	records := listJobs(func(r *JobRecord) bool {
+		return r.Kind == jobKindAttack && r.Type == attack.Type && r.Pod == podName
+	})
+	if len(records) > 0 {
+		return records[0].Container
+	}
+	return ""
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s-status-api/k8s"
+
+	"github.com/gin-gonic/gin"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// GroundTruthRecord describes when an attack ran, from which UE and against
+// which target, so that captured flows can be labelled
+type GroundTruthRecord struct {
+	ID         string       `json:"id"` // Job ID of the attack
+	AttackType string       `json:"attackType"`
+	AttackName string       `json:"attackName"`
+	Pod        string       `json:"pod"`
+	UEIP       string       `json:"ueIP"` // Address of uesimtun0 in the attacking pod, or the pod IP for pod network attacks
+	TargetIP   string       `json:"targetIP,omitempty"`
+	Mode       string       `json:"mode,omitempty"` // Sub-class of attacks with modes, e.g. the DDoS "syn" flood
+	Params     AttackParams `json:"params"`
+	StartedAt  time.Time    `json:"startedAt"`
+	EndedAt    *time.Time   `json:"endedAt,omitempty"` // Nil while the attack is running
+	ExitReason string       `json:"exitReason,omitempty"`
+}
+
+// overlaps reports whether the record's window intersects [from, to]. Zero
+// bounds are open.
+func (r *GroundTruthRecord) overlaps(from, to time.Time) bool {
+	if !to.IsZero() && r.StartedAt.After(to) {
+		return false
+	}
+	if !from.IsZero() && r.EndedAt != nil && r.EndedAt.Before(from) {
+		return false
+	}
+	return true
+}
+
+// The ground truth is appended to a JSON lines file. A record is written when
+// the attack starts and again when it ends; the last line for an ID wins.
+var groundTruthPath string
+var groundTruthRecords = make(map[string]*GroundTruthRecord)
+var groundTruthMutex sync.Mutex
+
+// OpenGroundTruth loads the records already in path and appends new ones to it
+func OpenGroundTruth(path string) error {
+	groundTruthMutex.Lock()
+	defer groundTruthMutex.Unlock()
+
+	groundTruthPath = path
+	groundTruthRecords = make(map[string]*GroundTruthRecord)
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record GroundTruthRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// A crash can leave a truncated last line, keep everything before it
+			consoleLog("[GROUND-TRUTH] Skipping invalid line %d in %s: %v\n", line, path, err)
+			continue
+		}
+		groundTruthRecords[record.ID] = &record
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	consoleLog("[GROUND-TRUTH] Loaded %d records from %s\n", len(groundTruthRecords), path)
+	return nil
+}
+
+// saveGroundTruth appends a record to the ground truth file. The caller must
+// hold groundTruthMutex.
+func saveGroundTruth(record *GroundTruthRecord) error {
+	groundTruthRecords[record.ID] = record
+	if groundTruthPath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(groundTruthPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+// recordAttackStart writes the ground truth for a freshly launched attack
+func recordAttackStart(clientset *kubernetes.Clientset, job *attackJob) {
+	ueIP, err := attackSourceIP(clientset, job)
+	if err != nil {
+		consoleLog("[GROUND-TRUTH] Could not resolve UE IP of pod %s: %v\n", job.PodName, err)
+	}
+
+	groundTruthMutex.Lock()
+	defer groundTruthMutex.Unlock()
+	record := &GroundTruthRecord{
+		ID:         job.ID,
+		AttackType: job.Attack.Type,
+		AttackName: job.Attack.Name,
+		Pod:        job.PodName,
+		UEIP:       ueIP,
+		TargetIP:   job.TargetIP,
+		Mode:       job.Params.Mode,
+		Params:     job.Params,
+		StartedAt:  job.StartedAt,
+	}
+	if err := saveGroundTruth(record); err != nil {
+		consoleLog("[GROUND-TRUTH] Failed to record start of %s: %v\n", job.ID, err)
+	}
+}
+
+// attackSourceIP returns the address the attack's packets leave the pod from
+func attackSourceIP(clientset *kubernetes.Clientset, job *attackJob) (string, error) {
+	if !job.Attack.PodNetwork {
+		return getPodIP(clientset, job.PodName)
+	}
+	pod, err := clientset.CoreV1().Pods(k8s.DefaultNamespace).Get(context.Background(), job.PodName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if pod.Status.PodIP == "" {
+		return "", fmt.Errorf("pod %s has no IP", job.PodName)
+	}
+	return pod.Status.PodIP, nil
+}
+
+// recordAttackEnd closes the ground truth window of an attack
+func recordAttackEnd(id string, endedAt time.Time, reason string) {
+	groundTruthMutex.Lock()
+	defer groundTruthMutex.Unlock()
+	existing, ok := groundTruthRecords[id]
+	if !ok {
+		return
+	}
+	record := *existing
+	record.EndedAt = &endedAt
+	record.ExitReason = reason
+	if err := saveGroundTruth(&record); err != nil {
+		consoleLog("[GROUND-TRUTH] Failed to record end of %s: %v\n", id, err)
+	}
+}
+
+// groundTruthFilter selects records for queries and exports
+type groundTruthFilter struct {
+	AttackType string
+	Pod        string
+	UEIP       string
+	Mode       string
+	From       time.Time
+	To         time.Time
+}
+
+// queryGroundTruth returns the matching records ordered by start time
+func queryGroundTruth(filter groundTruthFilter) []GroundTruthRecord {
+	groundTruthMutex.Lock()
+	defer groundTruthMutex.Unlock()
+
+	records := make([]GroundTruthRecord, 0, len(groundTruthRecords))
+	for _, record := range groundTruthRecords {
+		if filter.AttackType != "" && record.AttackType != filter.AttackType {
+			continue
+		}
+		if filter.Pod != "" && record.Pod != filter.Pod {
+			continue
+		}
+		if filter.UEIP != "" && record.UEIP != filter.UEIP {
+			continue
+		}
+		if filter.Mode != "" && record.Mode != filter.Mode {
+			continue
+		}
+		if !record.overlaps(filter.From, filter.To) {
+			continue
+		}
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].StartedAt.Before(records[j].StartedAt) })
+	return records
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter
+func parseTimeParam(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return t, nil
+}
+
+// GetGroundTruth returns the recorded attack windows, optionally filtered by
+// type, pod, ueIP, mode and a from/to time range
+func GetGroundTruth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, err := parseTimeParam(c, "from")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		to, err := parseTimeParam(c, "to")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		records := queryGroundTruth(groundTruthFilter{
+			AttackType: c.Query("type"),
+			Pod:        c.Query("pod"),
+			UEIP:       c.Query("ueIP"),
+			Mode:       c.Query("mode"),
+			From:       from,
+			To:         to,
+		})
+		c.JSON(http.StatusOK, gin.H{
+			"records": records,
+			"count":   len(records),
+		})
+	}
+}
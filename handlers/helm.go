@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -36,6 +37,56 @@ type UninstallRequest struct {
 	DeploymentName string `json:"deploymentName" binding:"required"`
 }
 
+const (
+	// ueransimChart and ueransimChartVersion are the UERANSIM chart releases
+	// are installed from
+	ueransimChart        = "oci://registry-1.docker.io/gradiant/ueransim-gnb"
+	ueransimChartVersion = "0.2.6"
+)
+
+// ueransimValues returns the testbed's gNB values with ueCount UE pods
+// numbered from initialMSISDN. A zero ueCount deploys the gNB only.
+func ueransimValues(ueCount int, initialMSISDN string) HelmValues {
+	values := HelmValues{}
+	values.AMF.Hostname = "open5gs-amf-ngap"
+	values.MCC = "999"
+	values.MNC = "70"
+	values.SST = 1
+	values.SD = "0x111111"
+	values.TAC = "0001"
+	values.UEs.Enabled = ueCount > 0
+	values.UEs.Count = ueCount
+	values.UEs.InitialMSISDN = initialMSISDN
+	return values
+}
+
+// helmInstallUERANSIM installs a release of the UERANSIM chart with values,
+// returning helm's output
+func helmInstallUERANSIM(release string, values HelmValues) ([]byte, error) {
+	tempDir, err := os.MkdirTemp("", "helm-values-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	valuesFile := filepath.Join(tempDir, "values.yaml")
+	valuesData, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create values file: %v", err)
+	}
+	if err := os.WriteFile(valuesFile, valuesData, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write values file: %v", err)
+	}
+
+	output, err := exec.Command("helm", "install", release, ueransimChart,
+		"--version", ueransimChartVersion,
+		"--values", valuesFile).CombinedOutput()
+	if err != nil {
+		return output, fmt.Errorf("helm install failed: %v: %s", err, output)
+	}
+	return output, nil
+}
+
 // InstallUERANSIM handles the Helm installation with dynamic values
 func InstallUERANSIM() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -44,56 +95,26 @@ func InstallUERANSIM() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
 			return
 		}
-
-		// Create values structure
-		values := HelmValues{}
-		values.AMF.Hostname = "open5gs-amf-ngap"
-		values.MCC = "999"
-		values.MNC = "70"
-		values.SST = 1
-		values.SD = "0x111111"
-		values.TAC = "0001"
-		values.UEs.Enabled = true
-		values.UEs.Count = 1
-		values.UEs.InitialMSISDN = req.InitialMSISDN
-
-		// Create temporary directory for values file
-		tempDir, err := os.MkdirTemp("", "helm-values-*")
-		if err != nil {
-			log.Printf("Error creating temp directory: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create temporary directory"})
-			return
-		}
-		defer os.RemoveAll(tempDir)
-
-		// Create values file
-		valuesFile := filepath.Join(tempDir, "values.yaml")
-		valuesData, err := json.Marshal(values)
-		if err != nil {
-			log.Printf("Error marshaling values: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create values file"})
+		if err := validateReleaseName("deploymentName", req.DeploymentName); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		// Write values to file
-		if err := os.WriteFile(valuesFile, valuesData, 0644); err != nil {
-			log.Printf("Error writing values file: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write values file"})
+		if err := validateMSISDN("initialMSISDN", req.InitialMSISDN); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Execute Helm command
-		cmd := exec.Command("helm", "install", req.DeploymentName,
-			"oci://registry-1.docker.io/gradiant/ueransim-gnb",
-			"--version", "0.2.6",
-			"--values", valuesFile)
-
-		output, err := cmd.CombinedOutput()
+		// Install one UE pod numbered from the requested MSISDN
+		output, err := helmInstallUERANSIM(req.DeploymentName, ueransimValues(1, req.InitialMSISDN))
 		if err != nil {
-			log.Printf("Error executing Helm command: %v\nOutput: %s", err, output)
+			log.Printf("Error installing UERANSIM: %v", err)
+			details := string(output)
+			if output == nil {
+				details = err.Error()
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Failed to install UERANSIM",
-				"details": string(output),
+				"details": details,
 			})
 			return
 		}
@@ -113,6 +134,10 @@ func UninstallUERANSIM() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
 			return
 		}
+		if err := validateReleaseName("deploymentName", req.DeploymentName); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		// Execute Helm uninstall command
 		cmd := exec.Command("helm", "uninstall", req.DeploymentName)
@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"k8s-status-api/k8s"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Kinds of jobs kept in the job store
+const (
+	jobKindAttack  = "attack"
+	jobKindTraffic = "traffic"
+)
+
+// Reconciliation retries a job check this many times, doubling the wait from
+// reconcileBackoff, before watching the job as still running
+const (
+	reconcileAttempts = 4
+	reconcileBackoff  = 2 * time.Second
+)
+
+// Job states besides the exit reasons of attack jobs
+const (
+	jobRunning = "running"
+	jobFailed  = "failed" // The traffic script exited with an error
+	jobLost    = "lost"   // The process vanished while the backend was not watching
+)
+
+// JobRecord is the durable description of an attack or traffic job
+type JobRecord struct {
+	ID         string       `json:"id"`
+	Kind       string       `json:"kind"`
+	Type       string       `json:"type"` // Attack type, or "traffic"
+	Pod        string       `json:"pod"`
+	Container  string       `json:"container,omitempty"` // Ephemeral container running the job, if any
+	TargetIP   string       `json:"targetIP,omitempty"`
+	Params     AttackParams `json:"params"`
+	PID        string       `json:"pid,omitempty"`
+	LogFile    string       `json:"logFile,omitempty"` // Attack output inside the pod
+	MonitorPod string       `json:"monitorPod,omitempty"`
+	StartedAt  time.Time    `json:"startedAt"`
+	Deadline   *time.Time   `json:"deadline,omitempty"`
+	EndedAt    *time.Time   `json:"endedAt,omitempty"`
+	State      string       `json:"state"`
+	// AllowConcurrent is set when the attack shares its pod with other attacks
+	AllowConcurrent bool `json:"allowConcurrent,omitempty"`
+	// Throughput summarizes the interface rates measured while the job ran
+	Throughput []throughputSummary `json:"throughput,omitempty"`
+}
+
+// The job store is a JSON file rewritten atomically on every change
+var jobStorePath string
+var jobRecords = make(map[string]*JobRecord)
+var jobStoreMutex sync.Mutex
+
+// OpenJobStore loads the jobs recorded in path and persists new ones to it
+func OpenJobStore(path string) error {
+	jobStoreMutex.Lock()
+	defer jobStoreMutex.Unlock()
+
+	jobStorePath = path
+	jobRecords = make(map[string]*JobRecord)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var records []*JobRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return fmt.Errorf("failed to parse job store %s: %v", path, err)
+	}
+	for _, record := range records {
+		jobRecords[record.ID] = record
+	}
+	consoleLog("[JOBS] Loaded %d jobs from %s\n", len(jobRecords), path)
+	return nil
+}
+
+// saveJobStore writes every record to the store file. The caller must hold
+// jobStoreMutex.
+func saveJobStore() error {
+	if jobStorePath == "" {
+		return nil
+	}
+	records := make([]*JobRecord, 0, len(jobRecords))
+	for _, record := range jobRecords {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].StartedAt.Before(records[j].StartedAt) })
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file and rename it, so a crash never leaves a half written store
+	tmp, err := os.CreateTemp(filepath.Dir(jobStorePath), filepath.Base(jobStorePath)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), jobStorePath)
+}
+
+// putJob inserts or replaces a job record
+func putJob(record JobRecord) {
+	jobStoreMutex.Lock()
+	defer jobStoreMutex.Unlock()
+	jobRecords[record.ID] = &record
+	if err := saveJobStore(); err != nil {
+		consoleLog("[JOBS] Failed to save job %s: %v\n", record.ID, err)
+	}
+}
+
+// endJob moves a running job to a final state
+func endJob(id, state string, endedAt time.Time) {
+	jobStoreMutex.Lock()
+	defer jobStoreMutex.Unlock()
+	record, ok := jobRecords[id]
+	if !ok || record.State != jobRunning {
+		return
+	}
+	record.State = state
+	record.EndedAt = &endedAt
+	if err := saveJobStore(); err != nil {
+		consoleLog("[JOBS] Failed to save job %s: %v\n", id, err)
+	}
+}
+
+// setJobThroughput saves the throughput measured for a job
+func setJobThroughput(id string, summary []throughputSummary) {
+	jobStoreMutex.Lock()
+	defer jobStoreMutex.Unlock()
+	record, ok := jobRecords[id]
+	if !ok {
+		return
+	}
+	record.Throughput = summary
+	if err := saveJobStore(); err != nil {
+		consoleLog("[JOBS] Failed to save job %s: %v\n", id, err)
+	}
+}
+
+// listJobs returns copies of the records accepted by keep, newest first
+func listJobs(keep func(*JobRecord) bool) []JobRecord {
+	jobStoreMutex.Lock()
+	defer jobStoreMutex.Unlock()
+	records := make([]JobRecord, 0, len(jobRecords))
+	for _, record := range jobRecords {
+		if keep(record) {
+			records = append(records, *record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].StartedAt.After(records[j].StartedAt) })
+	return records
+}
+
+// attackJobRecord describes an attack job for the store
+func attackJobRecord(job *attackJob) JobRecord {
+	record := JobRecord{
+		ID:              job.ID,
+		Kind:            jobKindAttack,
+		Type:            job.Attack.Type,
+		Pod:             job.PodName,
+		Container:       job.Container,
+		TargetIP:        job.TargetIP,
+		Params:          job.Params,
+		PID:             job.PID,
+		LogFile:         job.LogFile,
+		MonitorPod:      job.MonitorPod,
+		StartedAt:       job.StartedAt,
+		State:           jobRunning,
+		AllowConcurrent: job.AllowConcurrent,
+	}
+	if !job.Deadline.IsZero() {
+		deadline := job.Deadline
+		record.Deadline = &deadline
+	}
+	return record
+}
+
+// newTrafficJobID names a traffic job starting now
+func newTrafficJobID() string {
+	return TrafficPhase + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// startTrafficJob records a traffic test starting in a pod
+func startTrafficJob(id, podName string, allowConcurrent bool) {
+	putJob(JobRecord{ID: id, Kind: jobKindTraffic, Type: TrafficPhase, Pod: podName, StartedAt: time.Now(), State: jobRunning, AllowConcurrent: allowConcurrent})
+}
+
+// stopTrafficJobs marks every running traffic job in the pod as stopped and
+// frees the pod of their leases
+func stopTrafficJobs(podName string) {
+	now := time.Now()
+	for _, record := range listJobs(func(r *JobRecord) bool {
+		return r.Kind == jobKindTraffic && r.Pod == podName && r.State == jobRunning
+	}) {
+		endJob(record.ID, exitStopped, now)
+		releasePodLease(podName, record.ID)
+	}
+}
+
+// ReconcileJobs checks the jobs the store still considers running against the
+// pods and returns once every job is settled. Attacks and traffic tests that
+// are still alive are watched again, and jobs whose pod or process is
+// confirmed gone are marked lost. A job that cannot be checked, e.g. while the
+// API server or the pod is not ready yet, stays running and is watched again,
+// so that it keeps its pod.
+func ReconcileJobs(clientset *kubernetes.Clientset) {
+	running := listJobs(func(r *JobRecord) bool { return r.State == jobRunning })
+	if len(running) == 0 {
+		return
+	}
+	consoleLog("[JOBS] Reconciling %d running jobs\n", len(running))
+
+	var wg sync.WaitGroup
+	for _, record := range running {
+		wg.Add(1)
+		go func(record JobRecord) {
+			defer wg.Done()
+			reconcileJob(clientset, record)
+		}(record)
+	}
+	wg.Wait()
+	consoleLog("[JOBS] Reconciled %d running jobs\n", len(running))
+}
+
+// reconcileJob settles one job left running by a previous run, retrying
+// checks that fail with backoff
+func reconcileJob(clientset *kubernetes.Clientset, record JobRecord) {
+	if record.Kind == jobKindAttack {
+		if _, ok := LookupAttack(record.Type); !ok {
+			consoleLog("[JOBS] Attack type %q of job %s is no longer registered, marking lost\n", record.Type, record.ID)
+			markJobLost(record)
+			return
+		}
+	}
+
+	backoff := reconcileBackoff
+	for attempt := 1; ; attempt++ {
+		alive, err := jobProcessAlive(clientset, record)
+		if err == nil && !alive {
+			consoleLog("[JOBS] Job %s in pod %s has vanished, marking lost\n", record.ID, record.Pod)
+			markJobLost(record)
+			return
+		}
+		if err == nil {
+			consoleLog("[JOBS] Job %s in pod %s is still running\n", record.ID, record.Pod)
+			break
+		}
+		if gone, getErr := podGone(clientset, record.Pod); getErr == nil && gone {
+			consoleLog("[JOBS] Pod %s of job %s no longer exists, marking lost\n", record.Pod, record.ID)
+			markJobLost(record)
+			return
+		}
+		if attempt == reconcileAttempts {
+			consoleLog("[JOBS] Job %s in pod %s could not be checked, watching it as running: %v\n", record.ID, record.Pod, err)
+			break
+		}
+		consoleLog("[JOBS] Failed to check job %s in pod %s, retrying in %s: %v\n", record.ID, record.Pod, backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	if record.Kind == jobKindAttack {
+		resumeAttackJob(clientset, record)
+	} else {
+		holdPodLease(trafficLeaseAttack, record.Pod, record.ID, record.AllowConcurrent, record.StartedAt)
+		go watchTrafficJob(clientset, record)
+	}
+}
+
+// markJobLost ends a job whose process vanished while nobody watched it
+func markJobLost(record JobRecord) {
+	now := time.Now()
+	endJob(record.ID, jobLost, now)
+	recordAttackEnd(record.ID, now, jobLost)
+}
+
+// podGone reports whether the pod has been deleted
+func podGone(clientset *kubernetes.Clientset, podName string) (bool, error) {
+	_, err := clientset.CoreV1().Pods(k8s.DefaultNamespace).Get(context.Background(), podName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return true, nil
+	}
+	return false, err
+}
+
+// watchTrafficJob ends a rediscovered traffic test's job once its script has
+// exited. The request that started the test died with the previous run, so
+// nothing else would end the job.
+func watchTrafficJob(clientset *kubernetes.Clientset, record JobRecord) {
+	ticker := time.NewTicker(attackPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if len(listJobs(func(r *JobRecord) bool { return r.ID == record.ID && r.State == jobRunning })) == 0 {
+			releasePodLease(record.Pod, record.ID)
+			return // Stopped through the API meanwhile
+		}
+		alive, err := jobProcessAlive(clientset, record)
+		if err != nil {
+			// The pod may be briefly unreachable, try again on the next tick
+			consoleLog("[JOBS] Failed to check job %s: %v\n", record.ID, err)
+			continue
+		}
+		if !alive {
+			consoleLog("[JOBS] Traffic job %s in pod %s has finished\n", record.ID, record.Pod)
+			endJob(record.ID, exitCompleted, time.Now())
+			releasePodLease(record.Pod, record.ID)
+			return
+		}
+	}
+}
+
+// jobProcessAlive checks the job's saved PID, or its script's process
+// pattern. Agent jobs ask the agent for their run.
+func jobProcessAlive(clientset *kubernetes.Clientset, record JobRecord) (bool, error) {
+	command := []string{"pgrep", "-f", trafficScriptPattern}
+	if record.Kind == jobKindAttack {
+		attack, ok := LookupAttack(record.Type)
+		if !ok {
+			return false, fmt.Errorf("attack type %q is no longer registered", record.Type)
+		}
+		if attack.Agent {
+			return agentRunAlive(clientset, record.Pod, record.Container, record.ID)
+		}
+		command = []string{"pgrep", "-f", attack.processPattern()}
+	}
+	if record.PID != "" {
+		command = []string{"ps", "-p", record.PID}
+	}
+	if _, err := containerExec(clientset, record.Pod, record.Container, command...); err != nil {
+		if noProcessFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// resumeAttackJob rebuilds the in-memory job of a rediscovered attack, leases
+// its pod again and watches it
+func resumeAttackJob(clientset *kubernetes.Clientset, record JobRecord) {
+	attack, _ := LookupAttack(record.Type)
+	job := &attackJob{
+		ID:              record.ID,
+		Attack:          attack,
+		PodName:         record.Pod,
+		Container:       record.Container,
+		PID:             record.PID,
+		LogFile:         record.LogFile,
+		MonitorPod:      record.MonitorPod,
+		TargetIP:        record.TargetIP,
+		Params:          record.Params,
+		StartedAt:       record.StartedAt,
+		AllowConcurrent: record.AllowConcurrent,
+		throughput:      &throughputMonitor{},
+		done:            make(chan struct{}),
+	}
+	if record.Deadline != nil {
+		job.Deadline = *record.Deadline
+	}
+	holdPodLease(attack, record.Pod, record.ID, record.AllowConcurrent, record.StartedAt)
+
+	attackJobsMutex.Lock()
+	attackJobs[attackJobKey(attack, record.Pod)] = job
+	attackJobsMutex.Unlock()
+
+	go watchAttackJob(clientset, job)
+	go monitorThroughput(clientset, job)
+}
+
+// ListJobs returns the stored jobs, optionally filtered by kind, type, pod and state
+func ListJobs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind, jobType, pod, state := c.Query("kind"), c.Query("type"), c.Query("pod"), c.Query("state")
+		records := listJobs(func(r *JobRecord) bool {
+			return (kind == "" || r.Kind == kind) &&
+				(jobType == "" || r.Type == jobType) &&
+				(pod == "" || r.Pod == pod) &&
+				(state == "" || r.State == state)
+		})
+		c.JSON(http.StatusOK, gin.H{"jobs": records, "count": len(records)})
+	}
+}
+
+// GetJob returns one stored job
+func GetJob() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		records := listJobs(func(r *JobRecord) bool { return r.ID == id })
+		if len(records) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Unknown job: %s", id)})
+			return
+		}
+		c.JSON(http.StatusOK, records[0])
+	}
+}
@@ -1,288 +1,39 @@
 package handlers
 
 import (
-	"fmt"
-	"net/http"
-	"os"
-	"os/exec"
-	"strings"
-
 	"github.com/gin-gonic/gin"
 	"k8s.io/client-go/kubernetes"
 )
 
-// MalformedGTPURequest represents the request payload for Malformed GTP-U attack operations
-type MalformedGTPURequest struct {
-	PodName  string `json:"podName" binding:"required"`
-	TargetIP string `json:"targetIP"`
+func init() {
+	RegisterAttack(&Attack{
+		Type:          "malformed-gtpu",
+		Name:          "Malformed GTP-U attack",
+		LogTag:        "MAL-GTPU",
+		Label:         "MALFORMED_GTPU",
+		Script:        "attacks/malformed_gtpu.py",
+		WorkDir:       "/attack_scripts",
+		ScriptName:    "malformed_gtpu.py",
+		Launcher:      "malformed_gtpu_launcher.sh",
+		PIDFile:       "malformed_gtpu.pid",
+		Tools:         []string{"python3"},
+		PythonModules: []string{"scapy"},
+		Params:        []string{ParamPacketRate, ParamPayloadSize, ParamDuration, ParamTEIDRange},
+		Defaults:      AttackParams{PacketRate: 100},
+	})
 }
 
 // RunMalformedGTPUAttack handles executing a Malformed GTP-U attack from the pod
 func RunMalformedGTPUAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var req MalformedGTPURequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
-			return
-		}
-
-		// Direct console output with forced flush
-		consoleLog := func(format string, args ...interface{}) {
-			message := fmt.Sprintf(format, args...)
-			fmt.Print(message)
-			os.Stdout.Sync() // Force flush
-		}
-
-		consoleLog("[MAL-GTPU] Starting Malformed GTP-U attack setup for pod: %s\n", req.PodName)
-
-		// Step 1: Install required tools
-		consoleLog("[MAL-GTPU] Installing required tools in pod: %s\n", req.PodName)
-		installCmd := exec.Command("kubectl", "exec", req.PodName, "--", "apt-get", "update")
-		if output, err := installCmd.CombinedOutput(); err != nil {
-			consoleLog("[ERROR] Error updating apt: %v\nOutput: %s\n", err, output)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to update apt",
-				"details": string(output),
-			})
-			return
-		}
-
-		// Install Python and dependencies
-		installCmd = exec.Command("kubectl", "exec", req.PodName, "--", "apt", "install", "-y", 
-			"python3", "python3-pip")
-		if output, err := installCmd.CombinedOutput(); err != nil {
-			consoleLog("[ERROR] Error installing tools: %v\nOutput: %s\n", err, output)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to install required tools",
-				"details": string(output),
-			})
-			return
-		}
-
-		// Install required Python packages
-		pipInstallCmd := exec.Command("kubectl", "exec", req.PodName, "--", "pip3", "install", "scapy")
-		if output, err := pipInstallCmd.CombinedOutput(); err != nil {
-			consoleLog("[ERROR] Error installing Python packages: %v\nOutput: %s\n", err, output)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to install required Python packages",
-				"details": string(output),
-			})
-			return
-		}
-
-		// Step 2: Create directory for attack script
-		consoleLog("[MAL-GTPU] Creating directory for attack script...\n")
-		mkdirCmd := exec.Command("kubectl", "exec", req.PodName, "--", "mkdir", "-p", "/attack_scripts")
-		if output, err := mkdirCmd.CombinedOutput(); err != nil {
-			consoleLog("[ERROR] Error creating directory: %v\nOutput: %s\n", err, output)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to create directory",
-				"details": string(output),
-			})
-			return
-		}
-
-		// Step 3: Copy attack script to pod
-		consoleLog("[MAL-GTPU] Copying attack script to pod...\n")
-		copyCmd := exec.Command("kubectl", "cp", 
-			"/home/open5gs1/Documents/5g_attack_dataset/utills/Malformed GTP-U/malformed_gtp_u_corrupted_inner_packet.py", 
-			fmt.Sprintf("%s:/attack_scripts/malformed_gtpu.py", req.PodName))
-		if output, err := copyCmd.CombinedOutput(); err != nil {
-			consoleLog("[ERROR] Error copying script: %v\nOutput: %s\n", err, output)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to copy attack script",
-				"details": string(output),
-			})
-			return
-		}
-
-		// Step 4: Update the target IP in the script if specified
-		if req.TargetIP != "" {
-			consoleLog("[MAL-GTPU] Setting target IP to %s in the script...\n", req.TargetIP)
-			updateTargetCmd := exec.Command("kubectl", "exec", req.PodName, "--", "sed", "-i", 
-				fmt.Sprintf("s/dst=\"10\\.42\\.0\\.64\"/dst=\"%s\"/", req.TargetIP), 
-				"/attack_scripts/malformed_gtpu.py")
-			if output, err := updateTargetCmd.CombinedOutput(); err != nil {
-				consoleLog("[ERROR] Error updating target IP: %v\nOutput: %s\n", err, output)
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":   "Failed to update target IP in script",
-					"details": string(output),
-				})
-				return
-			}
-		}
-
-		// Step 5: Create a launch script that will properly daemonize the process
-		consoleLog("[MAL-GTPU] Creating launcher script...\n")
-		launcherCmd := exec.Command("kubectl", "exec", req.PodName, "--", "bash", "-c",
-			"cat > /attack_scripts/malformed_gtpu_launcher.sh << 'EOF'\n#!/bin/bash\npython3 /attack_scripts/malformed_gtpu.py > /dev/null 2>&1 &\necho $!\nEOF\n")
-		if output, err := launcherCmd.CombinedOutput(); err != nil {
-			consoleLog("[ERROR] Error creating launcher script: %v\nOutput: %s\n", err, output)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to create launcher script",
-				"details": string(output),
-			})
-			return
-		}
-
-		// Make launcher script executable
-		chmodCmd := exec.Command("kubectl", "exec", req.PodName, "--", "chmod", "+x", "/attack_scripts/malformed_gtpu_launcher.sh")
-		if output, err := chmodCmd.CombinedOutput(); err != nil {
-			consoleLog("[ERROR] Error setting script permissions: %v\nOutput: %s\n", err, output)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to set launcher script permissions",
-				"details": string(output),
-			})
-			return
-		}
-
-		// Step 6: Launch the attack script using the launcher script
-		consoleLog("[MAL-GTPU] Starting Malformed GTP-U attack...\n")
-		runCmd := exec.Command("kubectl", "exec", req.PodName, "--", "/attack_scripts/malformed_gtpu_launcher.sh")
-		output, err := runCmd.CombinedOutput()
-		if err != nil {
-			consoleLog("[ERROR] Error running attack: %v\nOutput: %s\n", err, output)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to run Malformed GTP-U attack",
-				"details": string(output),
-			})
-			return
-		}
-
-		// Save the process ID to a file for easier management
-		pid := strings.TrimSpace(string(output))
-		if pid != "" {
-			pidCmd := exec.Command("kubectl", "exec", req.PodName, "--", "bash", "-c", 
-				fmt.Sprintf("echo '%s' > /attack_scripts/malformed_gtpu.pid", pid))
-			pidCmd.CombinedOutput() // We don't need to check for errors here
-		}
-
-		consoleLog("[SUCCESS] Malformed GTP-U attack started successfully with PID: %s!\n", pid)
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Malformed GTP-U attack started successfully",
-			"pid":     pid,
-		})
-	}
+	return attackHandler(clientset, "malformed-gtpu", runAttack)
 }
 
 // StopMalformedGTPUAttack handles stopping the running Malformed GTP-U attack
 func StopMalformedGTPUAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var req MalformedGTPURequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
-			return
-		}
-
-		// Direct console output with forced flush
-		consoleLog := func(format string, args ...interface{}) {
-			message := fmt.Sprintf(format, args...)
-			fmt.Print(message)
-			os.Stdout.Sync() // Force flush
-		}
-
-		consoleLog("[MAL-GTPU] Stopping Malformed GTP-U attack for pod: %s\n", req.PodName)
-
-		// Check if we have a saved PID file
-		checkPidCmd := exec.Command("kubectl", "exec", req.PodName, "--", "bash", "-c", 
-			"if [ -f /attack_scripts/malformed_gtpu.pid ]; then cat /attack_scripts/malformed_gtpu.pid; else echo ''; fi")
-		pidBytes, err := checkPidCmd.CombinedOutput()
-		
-		if err == nil && len(pidBytes) > 0 {
-			// If we have a saved PID, use it to directly kill the process
-			pid := strings.TrimSpace(string(pidBytes))
-			if pid != "" {
-				consoleLog("[MAL-GTPU] Found saved PID: %s. Killing process...\n", pid)
-				killCmd := exec.Command("kubectl", "exec", req.PodName, "--", "kill", "-9", pid)
-				killCmd.CombinedOutput()
-			}
-		}
-
-		// Find and kill any Python processes running the attack script
-		consoleLog("[MAL-GTPU] Finding other attack processes...\n")
-		findCmd := exec.Command("kubectl", "exec", req.PodName, "--", "pgrep", "-f", "python3.*malformed_gtpu.py")
-		pids, err := findCmd.CombinedOutput()
-		if err == nil || !strings.Contains(string(pids), "No such process") {
-			// Kill all found processes
-			for _, pid := range strings.Split(strings.TrimSpace(string(pids)), "\n") {
-				if pid == "" {
-					continue
-				}
-				consoleLog("[MAL-GTPU] Killing process with PID: %s\n", pid)
-				killCmd := exec.Command("kubectl", "exec", req.PodName, "--", "kill", "-9", pid)
-				killCmd.CombinedOutput()
-			}
-		}
-
-		consoleLog("[SUCCESS] Malformed GTP-U attack stopped successfully!\n")
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Malformed GTP-U attack stopped successfully",
-		})
-	}
+	return attackHandler(clientset, "malformed-gtpu", stopAttackHandler)
 }
 
 // CheckMalformedGTPUAttackStatus checks if the Malformed GTP-U attack is running
 func CheckMalformedGTPUAttackStatus(clientset *kubernetes.Clientset) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var req MalformedGTPURequest
-		if err := c.ShouldBindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
-			return
-		}
-
-		// Direct console output with forced flush
-		consoleLog := func(format string, args ...interface{}) {
-			message := fmt.Sprintf(format, args...)
-			fmt.Print(message)
-			os.Stdout.Sync() // Force flush
-		}
-
-		consoleLog("[STATUS] Checking Malformed GTP-U attack status for pod: %s\n", req.PodName)
-
-		// First check if we have a saved PID file
-		checkPidCmd := exec.Command("kubectl", "exec", req.PodName, "--", "bash", "-c", 
-			"if [ -f /attack_scripts/malformed_gtpu.pid ]; then cat /attack_scripts/malformed_gtpu.pid; else echo ''; fi")
-		pidBytes, err := checkPidCmd.CombinedOutput()
-		
-		if err == nil && len(pidBytes) > 0 {
-			pid := strings.TrimSpace(string(pidBytes))
-			if pid != "" {
-				// Check if the process with this PID is still running
-				checkRunningCmd := exec.Command("kubectl", "exec", req.PodName, "--", "ps", "-p", pid)
-				if _, err := checkRunningCmd.CombinedOutput(); err == nil {
-					consoleLog("[STATUS] Malformed GTP-U attack is running with PID: %s\n", pid)
-					c.JSON(http.StatusOK, gin.H{
-						"status": "running",
-						"pid":    pid,
-					})
-					return
-				}
-			}
-		}
-
-		// If we don't have a PID file or the saved PID doesn't correspond to a running process,
-		// check for any running attack processes
-		checkCmd := exec.Command("kubectl", "exec", req.PodName, "--", "pgrep", "-f", "python3.*malformed_gtpu.py")
-		if output, err := checkCmd.CombinedOutput(); err != nil {
-			if strings.Contains(string(output), "No such process") {
-				consoleLog("[STATUS] No Malformed GTP-U attack is currently running.\n")
-				c.JSON(http.StatusOK, gin.H{
-					"status": "not running",
-				})
-			} else {
-				consoleLog("[ERROR] Error checking process status: %v\nOutput: %s\n", err, output)
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":   "Failed to check Malformed GTP-U attack status",
-					"details": string(output),
-				})
-			}
-			return
-		}
-
-		// If we get here, the process is running but we don't have a PID file
-		consoleLog("[STATUS] Malformed GTP-U attack is running.\n")
-		c.JSON(http.StatusOK, gin.H{
-			"status": "running",
-		})
-	}
-}
\ No newline at end of file
+	return attackHandler(clientset, "malformed-gtpu", checkAttackStatusHandler)
+}
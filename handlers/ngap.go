@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The NGAP attacks target the AMF's NGAP endpoint (SCTP 38412) over the pod
+// network, from a gNB or a dedicated attacker pod. Without a target IP the
+// scripts resolve open5gs-amf-ngap, the AMF hostname used by the Helm values.
+func init() {
+	RegisterAttack(&Attack{
+		Type:          "sctp-init-flood",
+		Name:          "SCTP INIT flood",
+		LogTag:        "SCTP-INIT",
+		Label:         "NGAP",
+		Script:        "attacks/sctp_init_flood.py",
+		WorkDir:       "/attack_scripts",
+		ScriptName:    "sctp_init_flood.py",
+		Launcher:      "sctp_init_flood_launcher.sh",
+		PIDFile:       "sctp_init_flood.pid",
+		PodNetwork:    true,
+		Tools:         []string{"python3"},
+		PythonModules: []string{"scapy"},
+		Params:        []string{ParamPacketRate, ParamDuration, ParamThreads, ParamSrcPortRange},
+		Defaults:      AttackParams{PacketRate: 1000, Threads: 1},
+	})
+	RegisterAttack(&Attack{
+		Type:       "ngap-malformed-setup",
+		Name:       "Malformed NGAP NG Setup attack",
+		LogTag:     "NGAP-SETUP",
+		Label:      "NGAP",
+		Script:     "attacks/ngap_malformed_setup.py",
+		WorkDir:    "/attack_scripts",
+		ScriptName: "ngap_malformed_setup.py",
+		Launcher:   "ngap_malformed_setup_launcher.sh",
+		PIDFile:    "ngap_malformed_setup.pid",
+		PodNetwork: true,
+		Tools:      []string{"python3"},
+		Params:     []string{ParamPacketRate, ParamDuration},
+		Defaults:   AttackParams{PacketRate: 10},
+	})
+	RegisterAttack(&Attack{
+		Type:       "ngap-ue-context-release",
+		Name:       "NGAP UE Context Release burst",
+		LogTag:     "NGAP-REL",
+		Label:      "NGAP",
+		Script:     "attacks/ngap_ue_context_release.py",
+		WorkDir:    "/attack_scripts",
+		ScriptName: "ngap_ue_context_release.py",
+		Launcher:   "ngap_ue_context_release_launcher.sh",
+		PIDFile:    "ngap_ue_context_release.pid",
+		PodNetwork: true,
+		Tools:      []string{"python3"},
+		Params:     []string{ParamPacketRate, ParamDuration},
+		Defaults:   AttackParams{PacketRate: 500},
+	})
+}
+
+// RunSCTPInitFloodAttack handles executing an SCTP INIT flood from the pod
+func RunSCTPInitFloodAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "sctp-init-flood", runAttack)
+}
+
+// StopSCTPInitFloodAttack handles stopping the running SCTP INIT flood
+func StopSCTPInitFloodAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "sctp-init-flood", stopAttackHandler)
+}
+
+// CheckSCTPInitFloodAttackStatus checks if the SCTP INIT flood is running
+func CheckSCTPInitFloodAttackStatus(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "sctp-init-flood", checkAttackStatusHandler)
+}
+
+// RunNGAPMalformedSetupAttack handles executing a Malformed NGAP NG Setup attack from the pod
+func RunNGAPMalformedSetupAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "ngap-malformed-setup", runAttack)
+}
+
+// StopNGAPMalformedSetupAttack handles stopping the running Malformed NGAP NG Setup attack
+func StopNGAPMalformedSetupAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "ngap-malformed-setup", stopAttackHandler)
+}
+
+// CheckNGAPMalformedSetupAttackStatus checks if the Malformed NGAP NG Setup attack is running
+func CheckNGAPMalformedSetupAttackStatus(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "ngap-malformed-setup", checkAttackStatusHandler)
+}
+
+// RunNGAPUEContextReleaseAttack handles executing an NGAP UE Context Release burst from the pod
+func RunNGAPUEContextReleaseAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "ngap-ue-context-release", runAttack)
+}
+
+// StopNGAPUEContextReleaseAttack handles stopping the running NGAP UE Context Release burst
+func StopNGAPUEContextReleaseAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "ngap-ue-context-release", stopAttackHandler)
+}
+
+// CheckNGAPUEContextReleaseAttackStatus checks if the NGAP UE Context Release burst is running
+func CheckNGAPUEContextReleaseAttackStatus(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "ngap-ue-context-release", checkAttackStatusHandler)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The PFCP attacks target the UPF's N4 address (UDP 8805) over the pod
+// network, spoofing the SMF's side of the session
+func init() {
+	RegisterAttack(&Attack{
+		Type:           "pfcp-session-deletion",
+		Name:           "PFCP Session Deletion attack",
+		LogTag:         "PFCP-DEL",
+		Label:          "PFCP_SESSION_DELETION",
+		Script:         "attacks/pfcp_session_deletion.py",
+		WorkDir:        "/attack_scripts",
+		ScriptName:     "pfcp_session_deletion.py",
+		Launcher:       "pfcp_session_deletion_launcher.sh",
+		PIDFile:        "pfcp_session_deletion.pid",
+		TargetRequired: true,
+		PodNetwork:     true,
+		Tools:          []string{"python3"},
+		PythonModules:  []string{"scapy"},
+		Params:         []string{ParamPacketRate, ParamDuration, ParamSEIDRange},
+		Defaults:       AttackParams{PacketRate: 100, SEIDRange: "1-65535"},
+	})
+	RegisterAttack(&Attack{
+		Type:           "pfcp-session-modification",
+		Name:           "PFCP Session Modification attack",
+		LogTag:         "PFCP-MOD",
+		Label:          "PFCP_SESSION_MODIFICATION",
+		Script:         "attacks/pfcp_session_modification.py",
+		WorkDir:        "/attack_scripts",
+		ScriptName:     "pfcp_session_modification.py",
+		Launcher:       "pfcp_session_modification_launcher.sh",
+		PIDFile:        "pfcp_session_modification.pid",
+		TargetRequired: true,
+		PodNetwork:     true,
+		Tools:          []string{"python3"},
+		PythonModules:  []string{"scapy"},
+		Params:         []string{ParamPacketRate, ParamDuration, ParamSEIDRange},
+		Defaults:       AttackParams{PacketRate: 100, SEIDRange: "1-65535"},
+	})
+	RegisterAttack(&Attack{
+		Type:           "pfcp-establishment-flood",
+		Name:           "PFCP Session Establishment flood",
+		LogTag:         "PFCP-EST",
+		Label:          "PFCP_ESTABLISHMENT_FLOOD",
+		Script:         "attacks/pfcp_establishment_flood.py",
+		WorkDir:        "/attack_scripts",
+		ScriptName:     "pfcp_establishment_flood.py",
+		Launcher:       "pfcp_establishment_flood_launcher.sh",
+		PIDFile:        "pfcp_establishment_flood.pid",
+		TargetRequired: true,
+		PodNetwork:     true,
+		Tools:          []string{"python3"},
+		PythonModules:  []string{"scapy"},
+		Params:         []string{ParamPacketRate, ParamDuration, ParamSEIDRange},
+		Defaults:       AttackParams{PacketRate: 1000},
+	})
+}
+
+// RunPFCPSessionDeletionAttack handles executing a PFCP Session Deletion attack from the pod
+func RunPFCPSessionDeletionAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "pfcp-session-deletion", runAttack)
+}
+
+// StopPFCPSessionDeletionAttack handles stopping the running PFCP Session Deletion attack
+func StopPFCPSessionDeletionAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "pfcp-session-deletion", stopAttackHandler)
+}
+
+// CheckPFCPSessionDeletionAttackStatus checks if the PFCP Session Deletion attack is running
+func CheckPFCPSessionDeletionAttackStatus(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "pfcp-session-deletion", checkAttackStatusHandler)
+}
+
+// RunPFCPSessionModificationAttack handles executing a PFCP Session Modification attack from the pod
+func RunPFCPSessionModificationAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "pfcp-session-modification", runAttack)
+}
+
+// StopPFCPSessionModificationAttack handles stopping the running PFCP Session Modification attack
+func StopPFCPSessionModificationAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "pfcp-session-modification", stopAttackHandler)
+}
+
+// CheckPFCPSessionModificationAttackStatus checks if the PFCP Session Modification attack is running
+func CheckPFCPSessionModificationAttackStatus(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "pfcp-session-modification", checkAttackStatusHandler)
+}
+
+// RunPFCPEstablishmentFloodAttack handles executing a PFCP Session Establishment flood from the pod
+func RunPFCPEstablishmentFloodAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "pfcp-establishment-flood", runAttack)
+}
+
+// StopPFCPEstablishmentFloodAttack handles stopping the running PFCP Session Establishment flood
+func StopPFCPEstablishmentFloodAttack(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "pfcp-establishment-flood", stopAttackHandler)
+}
+
+// CheckPFCPEstablishmentFloodAttackStatus checks if the PFCP Session Establishment flood is running
+func CheckPFCPEstablishmentFloodAttackStatus(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return attackHandler(clientset, "pfcp-establishment-flood", checkAttackStatusHandler)
+}
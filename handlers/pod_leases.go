@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxQueueSeconds bounds how long a launch may wait for a busy pod
+const maxQueueSeconds = 600
+
+// podLease records that a job holds a pod. A pod is held from the launch of
+// an attack until its job ends.
+type podLease struct {
+	JobID           string    `json:"jobId"`
+	Type            string    `json:"type"`
+	Pod             string    `json:"pod"`
+	AllowConcurrent bool      `json:"allowConcurrent"`
+	AcquiredAt      time.Time `json:"acquiredAt"`
+
+	agent bool // Runs in the pod's attack agent
+}
+
+// podLeases holds the leases of every pod, keyed by pod name
+var podLeases = make(map[string][]*podLease)
+var podLeasesMutex sync.Mutex
+
+// podLeaseReleased is closed and replaced whenever a lease is released, waking
+// the launches queued for a busy pod
+var podLeaseReleased = make(chan struct{})
+
+// podLocks serialize the launches and stops in each pod, so that concurrent
+// requests never work on the same PID and launcher files at once
+var podLocks = make(map[string]*sync.Mutex)
+var podLocksMutex sync.Mutex
+
+// lockPod takes the pod's lock and returns the function releasing it
+func lockPod(podName string) func() {
+	podLocksMutex.Lock()
+	lock, ok := podLocks[podName]
+	if !ok {
+		lock = &sync.Mutex{}
+		podLocks[podName] = lock
+	}
+	podLocksMutex.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+// trafficLeaseAttack stands for the traffic test in the leases of scenario
+// traffic phases. Two traffic tests never share a pod, and a traffic test
+// shares one with attacks only when both allow it.
+var trafficLeaseAttack = &Attack{Type: TrafficPhase, Name: "Traffic test", LogTag: "TRAFFIC"}
+
+// podConflictError is returned when a launch conflicts with the jobs holding the pod
+type podConflictError struct {
+	Pod     string
+	Holders []podLease
+}
+
+func (e *podConflictError) Error() string {
+	jobs := make([]string, 0, len(e.Holders))
+	for _, holder := range e.Holders {
+		jobs = append(jobs, fmt.Sprintf("%s (%s)", holder.JobID, holder.Type))
+	}
+	return fmt.Sprintf("pod %s is held by %s", e.Pod, strings.Join(jobs, ", "))
+}
+
+// podConflictDetails returns the holders of the pod a launch conflicted with
+func podConflictDetails(err error) ([]podLease, bool) {
+	var ce *podConflictError
+	if errors.As(err, &ce) {
+		return ce.Holders, true
+	}
+	return nil, false
+}
+
+// conflictsWith reports whether a new job of the attack may not share the pod
+// with the lease's job. Jobs of the same type share their PID and launcher
+// files, and the attack agent runs one run at a time, so those always
+// conflict. Other jobs may run side by side when both allow it.
+func (l *podLease) conflictsWith(attack *Attack, allowConcurrent bool) bool {
+	if l.Type == attack.Type || (l.agent && attack.Agent) {
+		return true
+	}
+	return !(l.AllowConcurrent && allowConcurrent)
+}
+
+// acquirePodLease takes a lease on the pod for the job. When the pod is held
+// by conflicting jobs it waits up to queue for them to end, then fails with a
+// podConflictError.
+func acquirePodLease(attack *Attack, podName, jobID string, allowConcurrent bool, queue time.Duration) error {
+	deadline := time.Now().Add(queue)
+	for {
+		podLeasesMutex.Lock()
+		var conflicts []podLease
+		for _, lease := range podLeases[podName] {
+			if lease.conflictsWith(attack, allowConcurrent) {
+				conflicts = append(conflicts, *lease)
+			}
+		}
+		if len(conflicts) == 0 {
+			podLeases[podName] = append(podLeases[podName], &podLease{
+				JobID:           jobID,
+				Type:            attack.Type,
+				Pod:             podName,
+				AllowConcurrent: allowConcurrent,
+				AcquiredAt:      time.Now(),
+				agent:           attack.Agent,
+			})
+			podLeasesMutex.Unlock()
+			return nil
+		}
+		released := podLeaseReleased
+		podLeasesMutex.Unlock()
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return &attackError{"Pod is busy", &podConflictError{Pod: podName, Holders: conflicts}}
+		}
+		consoleLog("[%s] Pod %s is busy, waiting up to %s for it\n", attack.LogTag, podName, wait.Round(time.Second))
+		timer := time.NewTimer(wait)
+		select {
+		case <-released:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// holdPodLease records the lease of a job that is already running, e.g. one
+// rediscovered on startup, without checking for conflicts
+func holdPodLease(attack *Attack, podName, jobID string, allowConcurrent bool, acquiredAt time.Time) {
+	podLeasesMutex.Lock()
+	defer podLeasesMutex.Unlock()
+	podLeases[podName] = append(podLeases[podName], &podLease{
+		JobID:           jobID,
+		Type:            attack.Type,
+		Pod:             podName,
+		AllowConcurrent: allowConcurrent,
+		AcquiredAt:      acquiredAt,
+		agent:           attack.Agent,
+	})
+}
+
+// releasePodLease drops the job's lease on the pod, if it holds one
+func releasePodLease(podName, jobID string) {
+	podLeasesMutex.Lock()
+	defer podLeasesMutex.Unlock()
+	leases := podLeases[podName]
+	for i, lease := range leases {
+		if lease.JobID != jobID {
+			continue
+		}
+		leases = append(leases[:i], leases[i+1:]...)
+		if len(leases) == 0 {
+			delete(podLeases, podName)
+		} else {
+			podLeases[podName] = leases
+		}
+		close(podLeaseReleased)
+		podLeaseReleased = make(chan struct{})
+		return
+	}
+}
+
+// revokePodLeases drops every lease on the pod, returning the IDs of the jobs
+// that held them. Launches still preparing the pod notice the revocation
+// before starting their process.
+func revokePodLeases(podName string) []string {
+	podLeasesMutex.Lock()
+	defer podLeasesMutex.Unlock()
+	var jobIDs []string
+	for _, lease := range podLeases[podName] {
+		jobIDs = append(jobIDs, lease.JobID)
+	}
+	if len(jobIDs) > 0 {
+		delete(podLeases, podName)
+		close(podLeaseReleased)
+		podLeaseReleased = make(chan struct{})
+	}
+	return jobIDs
+}
+
+// holdsPodLease reports whether the job still holds its lease on the pod
+func holdsPodLease(podName, jobID string) bool {
+	podLeasesMutex.Lock()
+	defer podLeasesMutex.Unlock()
+	for _, lease := range podLeases[podName] {
+		if lease.JobID == jobID {
+			return true
+		}
+	}
+	return false
+}
+
+// podLeaseHolders returns the leases held on the pod, oldest first
+func podLeaseHolders(podName string) []podLease {
+	podLeasesMutex.Lock()
+	defer podLeasesMutex.Unlock()
+	holders := make([]podLease, 0, len(podLeases[podName]))
+	for _, lease := range podLeases[podName] {
+		holders = append(holders, *lease)
+	}
+	return holders
+}
+
+// ListPodLeases returns the jobs holding each pod, optionally for one pod
+func ListPodLeases() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		podLeasesMutex.Lock()
+		leases := []podLease{}
+		for pod, held := range podLeases {
+			if name := c.Query("pod"); name != "" && name != pod {
+				continue
+			}
+			for _, lease := range held {
+				leases = append(leases, *lease)
+			}
+		}
+		podLeasesMutex.Unlock()
+
+		sort.Slice(leases, func(i, j int) bool {
+			if leases[i].Pod != leases[j].Pod {
+				return leases[i].Pod < leases[j].Pod
+			}
+			return leases[i].AcquiredAt.Before(leases[j].AcquiredAt)
+		})
+		c.JSON(http.StatusOK, gin.H{"leases": leases, "count": len(leases)})
+	}
+}
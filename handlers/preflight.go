@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"k8s-status-api/k8s"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// tunnelInterface is the UERANSIM interface carrying the UE's PDU session
+	tunnelInterface = "uesimtun0"
+	// podInterface is the pod's primary interface, which pod-network attacks send over
+	podInterface = "eth0"
+	// minFreeDiskKB is the free space needed for tools and scripts
+	minFreeDiskKB = 200 * 1024
+	// trafficTarget is the iperf3 server the traffic test talks to
+	trafficTarget = "10.42.0.99"
+)
+
+// trafficTools are the binaries the binning traffic test needs
+var trafficTools = []string{"python3", "iperf3"}
+
+// Preflight check results
+const (
+	checkPass = "pass"
+	checkWarn = "warn" // Not blocking, e.g. a tool that will be installed
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// preflightCheck is the result of one check
+type preflightCheck struct {
+	Name    string      `json:"name"`
+	Status  string      `json:"status"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// preflightReport collects the checks run against one pod
+type preflightReport struct {
+	Pod    string           `json:"pod"`
+	Passed bool             `json:"passed"` // False when any check failed
+	Checks []preflightCheck `json:"checks"`
+}
+
+func (r *preflightReport) add(name, status, message string, details interface{}) {
+	r.Checks = append(r.Checks, preflightCheck{Name: name, Status: status, Message: message, Details: details})
+	if status == checkFail {
+		r.Passed = false
+	}
+}
+
+// preflightError is returned when a launch is refused by its preflight checks
+type preflightError struct {
+	Report *preflightReport
+}
+
+func (e *preflightError) Error() string {
+	var failed []string
+	for _, check := range e.Report.Checks {
+		if check.Status == checkFail {
+			failed = append(failed, fmt.Sprintf("%s: %s", check.Name, check.Message))
+		}
+	}
+	return fmt.Sprintf("preflight checks failed for pod %s: %s", e.Report.Pod, strings.Join(failed, "; "))
+}
+
+// preflightTarget is what a preflight run prepares for
+type preflightTarget struct {
+	Tunnel        bool     // Whether traffic is sent through uesimtun0
+	TargetIP      string   // Checked for reachability when set
+	Tools         []string // Binaries that must be on PATH
+	RequiredTools []string // Binaries that must be on PATH and cannot be installed
+	PythonModules []string // Modules python3 must be able to import
+}
+
+// attackPreflightTarget returns what an attack run needs from the pod
+func attackPreflightTarget(attack *Attack, targetIP string) preflightTarget {
+	return preflightTarget{Tunnel: !attack.PodNetwork, TargetIP: targetIP, Tools: attack.Tools, RequiredTools: attack.RequiredTools, PythonModules: attack.PythonModules}
+}
+
+// trafficPreflightTarget returns what the traffic test needs from the pod
+func trafficPreflightTarget() preflightTarget {
+	return preflightTarget{Tunnel: true, TargetIP: trafficTarget, Tools: trafficTools}
+}
+
+// runPreflight checks a pod without changing anything in it. Later checks that
+// need a working exec session are skipped once the pod itself is unusable.
+func runPreflight(clientset *kubernetes.Clientset, podName string, target preflightTarget) *preflightReport {
+	report := &preflightReport{Pod: podName, Passed: true}
+
+	// Pod phase and readiness
+	pod, err := clientset.CoreV1().Pods(k8s.DefaultNamespace).Get(context.Background(), podName, metav1.GetOptions{})
+	if err != nil {
+		report.add("pod", checkFail, fmt.Sprintf("failed to get pod: %v", err), nil)
+		return report
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		report.add("pod", checkFail, fmt.Sprintf("pod is %s, not Running", pod.Status.Phase), nil)
+		return report
+	}
+	if !podReady(pod) {
+		report.add("pod", checkFail, "pod is Running but not Ready", nil)
+		return report
+	}
+	report.add("pod", checkPass, "pod is Running and Ready", nil)
+
+	// Tunnel interface and UE address
+	var ueIP string
+	if target.Tunnel {
+		ueIP, err = getPodIP(clientset, podName)
+		if err != nil {
+			report.add("tunnel", checkFail, fmt.Sprintf("%s is missing or has no address, is the PDU session up? (%v)", tunnelInterface, err), nil)
+		} else {
+			report.add("tunnel", checkPass, fmt.Sprintf("%s has address %s", tunnelInterface, ueIP), gin.H{"ueIP": ueIP})
+		}
+	} else {
+		report.add("tunnel", checkSkip, "sent over the pod network, no tunnel needed", nil)
+	}
+
+	// Free disk space for tools and scripts
+	if free, err := freeDiskKB(clientset, podName); err != nil {
+		report.add("disk", checkWarn, fmt.Sprintf("could not read free disk space: %v", err), nil)
+	} else if free < minFreeDiskKB {
+		report.add("disk", checkFail, fmt.Sprintf("only %d MB free, need %d MB", free/1024, minFreeDiskKB/1024), gin.H{"freeKB": free})
+	} else {
+		report.add("disk", checkPass, fmt.Sprintf("%d MB free", free/1024), gin.H{"freeKB": free})
+	}
+
+	// Tools and Python modules. Missing ones are installed at launch, so they only warn.
+	missing, err := missingTools(clientset, podName, target.Tools)
+	if err != nil {
+		report.add("tools", checkWarn, fmt.Sprintf("could not check tools: %v", err), nil)
+	} else if len(missing) > 0 {
+		report.add("tools", checkWarn, fmt.Sprintf("missing %s, they will be installed", strings.Join(missing, ", ")), gin.H{"missing": missing})
+	} else {
+		report.add("tools", checkPass, "all tools present", gin.H{"tools": target.Tools})
+	}
+
+	// Tools only the pod image can provide fail the preflight when missing
+	if len(target.RequiredTools) > 0 {
+		if missingRequired, err := missingTools(clientset, podName, target.RequiredTools); err != nil {
+			report.add("required-tools", checkWarn, fmt.Sprintf("could not check required tools: %v", err), nil)
+		} else if len(missingRequired) > 0 {
+			report.add("required-tools", checkFail, fmt.Sprintf("missing %s, which the pod image must provide", strings.Join(missingRequired, ", ")), gin.H{"missing": missingRequired})
+		} else {
+			report.add("required-tools", checkPass, "all required tools present", gin.H{"tools": target.RequiredTools})
+		}
+	}
+
+	if len(target.PythonModules) > 0 {
+		if contains(missing, "python3") {
+			report.add("python-modules", checkSkip, "python3 is not installed yet", nil)
+		} else if missingModules, err := missingPythonModules(clientset, podName, target.PythonModules); err != nil {
+			report.add("python-modules", checkWarn, fmt.Sprintf("could not check Python modules: %v", err), nil)
+		} else if len(missingModules) > 0 {
+			report.add("python-modules", checkWarn, fmt.Sprintf("missing %s, they will be installed", strings.Join(missingModules, ", ")), gin.H{"missing": missingModules})
+		} else {
+			report.add("python-modules", checkPass, "all Python modules present", gin.H{"modules": target.PythonModules})
+		}
+	}
+
+	// Reachability of the target through the tunnel, or the pod network.
+	// Targets may drop ICMP, so this only warns.
+	switch {
+	case target.TargetIP == "":
+		report.add("target", checkSkip, "no target IP", nil)
+	case target.Tunnel && ueIP == "":
+		report.add("target", checkSkip, "no tunnel to probe through", nil)
+	default:
+		report.addTargetCheck(clientset, podName, target.TargetIP, target.Tunnel)
+	}
+
+	return report
+}
+
+// addTargetCheck pings the target, through the tunnel interface when tunnel is set
+func (r *preflightReport) addTargetCheck(clientset *kubernetes.Clientset, podName, targetIP string, tunnel bool) {
+	if missing, err := missingTools(clientset, podName, []string{"ping"}); err != nil || len(missing) > 0 {
+		r.add("target", checkSkip, "ping is not available in the pod", nil)
+		return
+	}
+	command := []string{"ping", "-c", "2", "-W", "2", targetIP}
+	via := "the pod network"
+	if tunnel {
+		command = []string{"ping", "-c", "2", "-W", "2", "-I", tunnelInterface, targetIP}
+		via = tunnelInterface
+	}
+	if _, err := podExec(clientset, podName, command...); err != nil {
+		r.add("target", checkWarn, fmt.Sprintf("%s did not answer through %s", targetIP, via), errorDetails(err))
+		return
+	}
+	r.add("target", checkPass, fmt.Sprintf("%s is reachable through %s", targetIP, via), nil)
+}
+
+// podReady reports whether the pod's Ready condition is true
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// freeDiskKB returns the space available on the pod's root filesystem
+func freeDiskKB(clientset *kubernetes.Clientset, podName string) (int64, error) {
+	result, err := podExec(clientset, podName, "df", "-Pk", "/")
+	if err != nil {
+		return 0, err
+	}
+	lines := strings.Split(strings.TrimSpace(result.Stdout), "\n")
+	if len(lines) < 2 {
+		return 0, fmt.Errorf("unexpected df output: %q", result.Stdout)
+	}
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(fields) < 4 {
+		return 0, fmt.Errorf("unexpected df output: %q", result.Stdout)
+	}
+	return strconv.ParseInt(fields[3], 10, 64)
+}
+
+// missingTools returns the tools that are not on the pod's PATH
+func missingTools(clientset *kubernetes.Clientset, podName string, tools []string) ([]string, error) {
+	if len(tools) == 0 {
+		return nil, nil
+	}
+	script := `for tool in "$@"; do command -v "$tool" > /dev/null 2>&1 || echo "$tool"; done`
+	result, err := podExec(clientset, podName, append([]string{"sh", "-c", script, "sh"}, tools...)...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(result.Stdout), nil
+}
+
+// missingPythonModules returns the modules python3 in the pod cannot import
+func missingPythonModules(clientset *kubernetes.Clientset, podName string, modules []string) ([]string, error) {
+	if len(modules) == 0 {
+		return nil, nil
+	}
+	script := `import importlib.util, sys
+print(" ".join(m for m in sys.argv[1:] if importlib.util.find_spec(m) is None))`
+	result, err := podExec(clientset, podName, append([]string{"python3", "-c", script}, modules...)...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(result.Stdout), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPreflight runs the preflight checks ahead of a launch and turns a
+// failed report into an error
+func checkPreflight(clientset *kubernetes.Clientset, podName, tag string, target preflightTarget) error {
+	consoleLog("[%s] Running preflight checks in pod: %s\n", tag, podName)
+	report := runPreflight(clientset, podName, target)
+	for _, check := range report.Checks {
+		consoleLog("[%s] Preflight %s: %s (%s)\n", tag, check.Name, check.Status, check.Message)
+	}
+	if !report.Passed {
+		return &attackError{"Preflight checks failed", &preflightError{report}}
+	}
+	return nil
+}
+
+// preflightDetails returns the report of a preflight failure
+func preflightDetails(err error) (*preflightReport, bool) {
+	var pe *preflightError
+	if errors.As(err, &pe) {
+		return pe.Report, true
+	}
+	return nil, false
+}
+
+// PreflightRequest selects the pods and the attack or traffic test to check for
+type PreflightRequest struct {
+	AttackRequest
+	Type string `json:"type" form:"type" binding:"required"` // Attack type, or "traffic"
+}
+
+// Preflight checks pods without changing anything in them and returns a
+// pass/fail report per pod
+func Preflight(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PreflightRequest
+		var err error
+		if c.Request.Method == http.MethodGet && c.Request.ContentLength <= 0 {
+			err = c.ShouldBindQuery(&req)
+		} else {
+			err = c.ShouldBindJSON(&req)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+			return
+		}
+
+		target := trafficPreflightTarget()
+		if req.Type != TrafficPhase {
+			attack, ok := LookupAttack(req.Type)
+			if !ok {
+				c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Unknown attack type: %s", req.Type)})
+				return
+			}
+			if req.TargetIP != "" {
+				if err := validateTargetIP(req.TargetIP); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			target = attackPreflightTarget(attack, req.TargetIP)
+		}
+
+		pods, err := resolveAttackPods(clientset, &req.AttackRequest)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		reports := fanOutPods(pods, req.Concurrency, func(pod string) gin.H {
+			report := runPreflight(clientset, pod, target)
+			return gin.H{"passed": report.Passed, "checks": report.Checks}
+		})
+
+		passed := 0
+		for _, report := range reports {
+			if report["passed"] == true {
+				passed++
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"type":    req.Type,
+			"passed":  passed == len(pods),
+			"total":   len(pods),
+			"failed":  len(pods) - passed,
+			"reports": reports,
+		})
+	}
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"k8s-status-api/k8s"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// toolPackages maps tools to the apt package providing them, when the names differ
+var toolPackages = map[string]string{
+	"pip3": "python3-pip",
+	"ping": "iputils-ping",
+}
+
+// modulePackages maps Python modules to the pip package providing them, when the names differ
+var modulePackages = map[string]string{}
+
+const (
+	// offlineBinDir is where offline binaries are installed inside pods
+	offlineBinDir = "/usr/local/bin"
+	// offlineWheelDir is where offline wheels are staged inside pods
+	offlineWheelDir = "/tmp/offline_wheels"
+)
+
+// Offline provisioning: static binaries in <toolsDir>/bin and wheels in
+// <toolsDir>/wheels are pushed from the backend host instead of installed
+// from the internet. With offlineTools set, apt and pip are never used.
+var toolsDir string
+var offlineTools bool
+
+// provisioned caches, per pod UID, the tools and modules known to be present.
+// A recreated pod gets a new UID and is checked again.
+var provisioned = make(map[types.UID]map[string]bool)
+var provisionedMutex sync.Mutex
+
+// SetToolsDir configures the offline tools directory and whether only it may be used
+func SetToolsDir(dir string, offline bool) {
+	toolsDir = dir
+	offlineTools = offline
+}
+
+func packageFor(mapping map[string]string, name string) string {
+	if pkg, ok := mapping[name]; ok {
+		return pkg
+	}
+	return name
+}
+
+// toolKey and moduleKey keep tools and modules with the same name apart in the cache
+func toolKey(tool string) string     { return "tool:" + tool }
+func moduleKey(module string) string { return "module:" + module }
+
+// uncached returns the names whose keys are not cached as present for the pod
+func uncached(uid types.UID, names []string, key func(string) string) []string {
+	provisionedMutex.Lock()
+	defer provisionedMutex.Unlock()
+	var missing []string
+	for _, name := range names {
+		if !provisioned[uid][key(name)] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// markProvisioned caches names as present in the pod
+func markProvisioned(uid types.UID, names []string, key func(string) string) {
+	provisionedMutex.Lock()
+	defer provisionedMutex.Unlock()
+	if provisioned[uid] == nil {
+		provisioned[uid] = make(map[string]bool)
+	}
+	for _, name := range names {
+		provisioned[uid][key(name)] = true
+	}
+}
+
+// provisionTools makes sure the tools and Python modules are present in the
+// pod, installing only what is missing
+func provisionTools(clientset *kubernetes.Clientset, podName, tag string, tools, modules []string) error {
+	pod, err := clientset.CoreV1().Pods(k8s.DefaultNamespace).Get(context.Background(), podName, metav1.GetOptions{})
+	if err != nil {
+		return &attackError{"Failed to look up pod", err}
+	}
+	uid := pod.UID
+
+	// Modules are checked once python3 is known to be present
+	if err := provisionBinaries(clientset, podName, tag, uid, tools); err != nil {
+		return err
+	}
+	if err := provisionModules(clientset, podName, tag, uid, modules); err != nil {
+		return err
+	}
+	return nil
+}
+
+// provisionBinaries installs the tools that are not on the pod's PATH
+func provisionBinaries(clientset *kubernetes.Clientset, podName, tag string, uid types.UID, tools []string) error {
+	check := uncached(uid, tools, toolKey)
+	if len(check) == 0 {
+		consoleLog("[%s] Tools already provisioned in pod %s\n", tag, podName)
+		return nil
+	}
+
+	missing, err := missingTools(clientset, podName, check)
+	if err != nil {
+		return &attackError{"Failed to check installed tools", err}
+	}
+	markProvisioned(uid, subtract(check, missing), toolKey)
+	if len(missing) == 0 {
+		consoleLog("[%s] All tools present in pod %s\n", tag, podName)
+		return nil
+	}
+	consoleLog("[%s] Missing tools in pod %s: %s\n", tag, podName, strings.Join(missing, ", "))
+
+	// Push static binaries available on the backend host
+	var remaining []string
+	for _, tool := range missing {
+		local := filepath.Join(toolsDir, "bin", tool)
+		if toolsDir == "" || !fileExists(local) {
+			remaining = append(remaining, tool)
+			continue
+		}
+		if err := pushBinary(clientset, podName, tag, local, tool); err != nil {
+			return err
+		}
+	}
+
+	if len(remaining) > 0 {
+		if offlineTools {
+			return &attackError{"Failed to install required tools", fmt.Errorf("offline mode: no binaries for %s in %s", strings.Join(remaining, ", "), filepath.Join(toolsDir, "bin"))}
+		}
+		if err := aptInstall(clientset, podName, tag, remaining); err != nil {
+			return err
+		}
+	}
+
+	// Make sure the installation actually provided the tools
+	stillMissing, err := missingTools(clientset, podName, missing)
+	if err != nil {
+		return &attackError{"Failed to check installed tools", err}
+	}
+	if len(stillMissing) > 0 {
+		return &attackError{"Failed to install required tools", fmt.Errorf("still missing after installation: %s", strings.Join(stillMissing, ", "))}
+	}
+	markProvisioned(uid, missing, toolKey)
+	return nil
+}
+
+// provisionModules installs the Python modules python3 cannot import
+func provisionModules(clientset *kubernetes.Clientset, podName, tag string, uid types.UID, modules []string) error {
+	check := uncached(uid, modules, moduleKey)
+	if len(check) == 0 {
+		return nil
+	}
+
+	missing, err := missingPythonModules(clientset, podName, check)
+	if err != nil {
+		return &attackError{"Failed to check installed Python modules", err}
+	}
+	markProvisioned(uid, subtract(check, missing), moduleKey)
+	if len(missing) == 0 {
+		consoleLog("[%s] All Python modules present in pod %s\n", tag, podName)
+		return nil
+	}
+	consoleLog("[%s] Missing Python modules in pod %s: %s\n", tag, podName, strings.Join(missing, ", "))
+
+	packages := make([]string, 0, len(missing))
+	for _, module := range missing {
+		packages = append(packages, packageFor(modulePackages, module))
+	}
+
+	wheels, _ := filepath.Glob(filepath.Join(toolsDir, "wheels", "*.whl"))
+	switch {
+	case toolsDir != "" && len(wheels) > 0:
+		if err := installWheels(clientset, podName, tag, wheels, packages); err != nil {
+			return err
+		}
+	case offlineTools:
+		return &attackError{"Failed to install required Python packages", fmt.Errorf("offline mode: no wheels in %s", filepath.Join(toolsDir, "wheels"))}
+	default:
+		if err := provisionBinaries(clientset, podName, tag, uid, []string{"pip3"}); err != nil {
+			return err
+		}
+		consoleLog("[%s] Installing Python packages: %s\n", tag, strings.Join(packages, ", "))
+		pipArgs := append([]string{"pip3", "install"}, packages...)
+		if _, err := podExecTimeout(clientset, podName, installExecTimeout, pipArgs...); err != nil {
+			consoleLog("[ERROR] Error installing Python packages: %v\n", err)
+			return &attackError{"Failed to install required Python packages", err}
+		}
+	}
+
+	stillMissing, err := missingPythonModules(clientset, podName, missing)
+	if err != nil {
+		return &attackError{"Failed to check installed Python modules", err}
+	}
+	if len(stillMissing) > 0 {
+		return &attackError{"Failed to install required Python packages", fmt.Errorf("still missing after installation: %s", strings.Join(stillMissing, ", "))}
+	}
+	markProvisioned(uid, missing, moduleKey)
+	return nil
+}
+
+// aptInstall installs the packages providing the tools
+func aptInstall(clientset *kubernetes.Clientset, podName, tag string, tools []string) error {
+	packages := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		packages = append(packages, packageFor(toolPackages, tool))
+	}
+
+	consoleLog("[%s] Installing packages in pod %s: %s\n", tag, podName, strings.Join(packages, ", "))
+	if _, err := podExecTimeout(clientset, podName, installExecTimeout, "apt-get", "update"); err != nil {
+		consoleLog("[ERROR] Error updating apt: %v\n", err)
+		return &attackError{"Failed to update apt", err}
+	}
+	installArgs := append([]string{"apt", "install", "-y"}, packages...)
+	if _, err := podExecTimeout(clientset, podName, installExecTimeout, installArgs...); err != nil {
+		consoleLog("[ERROR] Error installing tools: %v\n", err)
+		return &attackError{"Failed to install required tools", err}
+	}
+	return nil
+}
+
+// pushBinary copies a static binary from the backend host into the pod
+func pushBinary(clientset *kubernetes.Clientset, podName, tag, local, tool string) error {
+	data, err := os.ReadFile(local)
+	if err != nil {
+		return &attackError{"Failed to read offline binary", err}
+	}
+	remote := offlineBinDir + "/" + tool
+	consoleLog("[%s] Pushing offline binary %s to %s\n", tag, local, remote)
+	if err := writePodFile(clientset, podName, "", remote, data); err != nil {
+		return &attackError{"Failed to copy offline binary", err}
+	}
+	if _, err := podExec(clientset, podName, "chmod", "+x", remote); err != nil {
+		return &attackError{"Failed to set offline binary permissions", err}
+	}
+	return nil
+}
+
+// installWheels stages the offline wheels in the pod and installs the
+// packages from them. Without pip3 the pure-Python wheels are unpacked into
+// site-packages directly.
+func installWheels(clientset *kubernetes.Clientset, podName, tag string, wheels, packages []string) error {
+	if _, err := podExec(clientset, podName, "mkdir", "-p", offlineWheelDir); err != nil {
+		return &attackError{"Failed to create wheel directory", err}
+	}
+	var remote []string
+	for _, wheel := range wheels {
+		data, err := os.ReadFile(wheel)
+		if err != nil {
+			return &attackError{"Failed to read offline wheel", err}
+		}
+		path := offlineWheelDir + "/" + filepath.Base(wheel)
+		consoleLog("[%s] Pushing offline wheel %s\n", tag, filepath.Base(wheel))
+		if err := writePodFile(clientset, podName, "", path, data); err != nil {
+			return &attackError{"Failed to copy offline wheel", err}
+		}
+		remote = append(remote, path)
+	}
+
+	missingPip, err := missingTools(clientset, podName, []string{"pip3"})
+	if err != nil {
+		return &attackError{"Failed to check installed tools", err}
+	}
+	if len(missingPip) == 0 {
+		pipArgs := append([]string{"pip3", "install", "--no-index", "--find-links", offlineWheelDir}, packages...)
+		if _, err := podExecTimeout(clientset, podName, installExecTimeout, pipArgs...); err != nil {
+			return &attackError{"Failed to install required Python packages", err}
+		}
+		return nil
+	}
+
+	consoleLog("[%s] pip3 is not available, unpacking wheels into site-packages\n", tag)
+	script := `import site, sys, zipfile
+target = site.getsitepackages()[0]
+for wheel in sys.argv[1:]:
+    zipfile.ZipFile(wheel).extractall(target)`
+	if _, err := podExecTimeout(clientset, podName, installExecTimeout, append([]string{"python3", "-c", script}, remote...)...); err != nil {
+		return &attackError{"Failed to install required Python packages", err}
+	}
+	return nil
+}
+
+// subtract returns the values not in remove
+func subtract(values, remove []string) []string {
+	var kept []string
+	for _, value := range values {
+		if !contains(remove, value) {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"path"
+	"regexp"
+	"time"
+
+	"k8s-status-api/k8s"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// stormPodTimeout bounds the wait for the release's gNB pod
+	stormPodTimeout = 3 * time.Minute
+	// amfPodPattern matches the AMF pod whose logs are counted
+	amfPodPattern = "open5gs-amf*"
+)
+
+func init() {
+	RegisterAttack(&Attack{
+		Type:           "registration-storm",
+		Name:           "Registration storm",
+		LogTag:         "REG-STORM",
+		Label:          "REGISTRATION_STORM",
+		Script:         "attacks/registration_storm.py",
+		WorkDir:        "/attack_scripts",
+		ScriptName:     "registration_storm.py",
+		Launcher:       "registration_storm_launcher.sh",
+		PIDFile:        "registration_storm.pid",
+		PodNetwork:     true,
+		ExtraProcesses: []string{"nr-ue -c /tmp/registration_storm_ue.yaml"},
+		Tools:          []string{"python3"},
+		RequiredTools:  []string{"nr-ue", "nr-cli"},
+		Params:         []string{ParamUECount, ParamRegRate, ParamHoldSeconds, ParamDuration},
+		Defaults:       AttackParams{UECount: 100, RegistrationRate: 10, HoldSeconds: 10},
+		Report:         registrationStormReport,
+	})
+}
+
+// amfLogCounters are the AMF log lines counted for a registration storm. The
+// AMF logs one line per NAS message, e.g.
+// "[gmm] INFO: [imsi-999700000000001] Registration complete".
+var amfLogCounters = []struct {
+	Name    string
+	Pattern *regexp.Regexp
+}{
+	{"registrationRequests", regexp.MustCompile(`Registration request`)},
+	{"registrationsComplete", regexp.MustCompile(`Registration complete`)},
+	{"registrationRejects", regexp.MustCompile(`Registration reject`)},
+	{"authenticationFailures", regexp.MustCompile(`Authentication (failure|reject)`)},
+	{"deregistrations", regexp.MustCompile(`(?i)de-?registration request`)},
+}
+
+// RegistrationStormRequest names the UERANSIM release whose gNB pod runs the
+// storm. The release is installed when it does not exist yet.
+type RegistrationStormRequest struct {
+	AttackRequest
+	Release string `json:"release" form:"release" binding:"required"`
+}
+
+// errStormReleaseMismatch marks an existing release the storm cannot use
+var errStormReleaseMismatch = errors.New("release does not match the storm's UERANSIM settings")
+
+// helmListEntry is one release of `helm list -o json`
+type helmListEntry struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Chart  string `json:"chart"`
+}
+
+// ensureStormRelease installs a gNB-only UERANSIM release unless it exists.
+// The storm's UEs are nr-ue instances started in the gNB pod itself, so the
+// release deploys no UE pods and ueCount needs no redeployment. An existing
+// release is only reused when it is a deployed gNB-only release of the same
+// chart with the PLMN and slice the storm's UEs register with.
+func ensureStormRelease(release string) error {
+	output, err := exec.Command("helm", "list", "--all", "--filter", "^"+regexp.QuoteMeta(release)+"$", "-o", "json").Output()
+	if err != nil {
+		return fmt.Errorf("helm list failed: %v", err)
+	}
+	var releases []helmListEntry
+	if err := json.Unmarshal(output, &releases); err != nil {
+		return fmt.Errorf("failed to parse helm list output: %v", err)
+	}
+	if len(releases) == 0 {
+		consoleLog("[REG-STORM] Installing UERANSIM release %s\n", release)
+		_, err := helmInstallUERANSIM(release, ueransimValues(0, ""))
+		return err
+	}
+
+	chart := path.Base(ueransimChart) + "-" + ueransimChartVersion
+	if releases[0].Chart != chart {
+		return fmt.Errorf("%w: %s runs chart %s, not %s", errStormReleaseMismatch, release, releases[0].Chart, chart)
+	}
+	if releases[0].Status != "deployed" {
+		return fmt.Errorf("%w: %s is %s, not deployed", errStormReleaseMismatch, release, releases[0].Status)
+	}
+
+	output, err = exec.Command("helm", "get", "values", release, "-o", "json").Output()
+	if err != nil {
+		return fmt.Errorf("helm get values failed: %v", err)
+	}
+	var values HelmValues
+	if err := json.Unmarshal(output, &values); err != nil {
+		return fmt.Errorf("failed to parse values of %s: %v", release, err)
+	}
+	if values.UEs.Enabled {
+		return fmt.Errorf("%w: %s deploys UE pods, whose IMSIs the storm's UEs would reuse", errStormReleaseMismatch, release)
+	}
+	want := ueransimValues(0, "")
+	values.UEs = want.UEs
+	if values != want {
+		return fmt.Errorf("%w: %s was installed with other AMF, PLMN, slice or TAC values", errStormReleaseMismatch, release)
+	}
+	return nil
+}
+
+// stormGNBPod waits for the running gNB pod of the release
+func stormGNBPod(clientset *kubernetes.Clientset, release string) (string, error) {
+	var podName string
+	err := wait.PollUntilContextTimeout(context.Background(), 2*time.Second, stormPodTimeout, true, func(ctx context.Context) (bool, error) {
+		pods, err := k8s.SelectPods(ctx, clientset, "", "app.kubernetes.io/instance="+release, "*gnb*")
+		if err != nil {
+			return false, err
+		}
+		if len(pods) == 0 {
+			return false, nil
+		}
+		podName = pods[0]
+		return true, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("no running gNB pod for release %s: %v", release, err)
+	}
+	return podName, nil
+}
+
+// bindStormRequest binds the request and resolves the release's gNB pod,
+// installing the release first when install is set
+func bindStormRequest(c *gin.Context, clientset *kubernetes.Clientset, install bool) (*Attack, AttackRequest, bool) {
+	attack, _ := LookupAttack("registration-storm")
+	var req RegistrationStormRequest
+	var err error
+	if c.Request.Method == http.MethodGet && c.Request.ContentLength <= 0 {
+		err = c.ShouldBindQuery(&req)
+	} else {
+		err = c.ShouldBindJSON(&req)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+		return nil, AttackRequest{}, false
+	}
+	if err := validateReleaseName("release", req.Release); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, AttackRequest{}, false
+	}
+
+	if install {
+		if err := attack.normalize(&req.AttackRequest); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, AttackRequest{}, false
+		}
+		if err := ensureStormRelease(req.Release); errors.Is(err, errStormReleaseMismatch) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return nil, AttackRequest{}, false
+		} else if err != nil {
+			consoleLog("[ERROR] Error installing UERANSIM release: %v\n", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to install UERANSIM", "details": err.Error()})
+			return nil, AttackRequest{}, false
+		}
+	}
+
+	pod, err := stormGNBPod(clientset, req.Release)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, AttackRequest{}, false
+	}
+	req.PodName = pod
+	return attack, req.AttackRequest, true
+}
+
+// RunRegistrationStorm deploys the UERANSIM release if needed and starts the
+// registration storm from its gNB pod
+func RunRegistrationStorm(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attack, req, ok := bindStormRequest(c, clientset, true)
+		if !ok {
+			return
+		}
+		response, err := launchAttackOnPod(clientset, attack, req)
+		if err != nil {
+			respondAttackError(c, err)
+			return
+		}
+		response["pod"] = req.PodName
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// StopRegistrationStorm stops the registration storm of a release
+func StopRegistrationStorm(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attack, req, ok := bindStormRequest(c, clientset, false)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, stopAttackOnPod(clientset, attack, req.PodName))
+	}
+}
+
+// CheckRegistrationStormStatus reports whether the registration storm of a
+// release is running, with the AMF's registration counts
+func CheckRegistrationStormStatus(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		attack, req, ok := bindStormRequest(c, clientset, false)
+		if !ok {
+			return
+		}
+		response, err := attackStatusOnPod(clientset, attack, req.PodName)
+		if err != nil {
+			respondAttackError(c, err)
+			return
+		}
+		response["pod"] = req.PodName
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// registrationStormReport counts the AMF's registration outcomes logged
+// since the job started
+func registrationStormReport(clientset *kubernetes.Clientset, job *attackJob) (map[string]interface{}, error) {
+	ctx := context.Background()
+	pods, err := k8s.SelectPods(ctx, clientset, "", "", amfPodPattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(pods) == 0 {
+		return nil, fmt.Errorf("no running pod matches %s", amfPodPattern)
+	}
+
+	j := job.snapshot()
+	since := metav1.NewTime(j.StartedAt)
+	stream, err := clientset.CoreV1().Pods(k8s.DefaultNamespace).GetLogs(pods[0], &corev1.PodLogOptions{SinceTime: &since}).Stream(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read logs of %s: %v", pods[0], err)
+	}
+	defer stream.Close()
+
+	counts := make(map[string]int)
+	for _, counter := range amfLogCounters {
+		counts[counter.Name] = 0
+	}
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		for _, counter := range amfLogCounters {
+			if counter.Pattern.MatchString(line) {
+				counts[counter.Name]++
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read logs of %s: %v", pods[0], err)
+	}
+
+	report := map[string]interface{}{"amfPod": pods[0], "since": j.StartedAt}
+	for name, count := range counts {
+		report[name] = count
+	}
+	if requests := counts["registrationRequests"]; requests > 0 {
+		report["successRate"] = float64(counts["registrationsComplete"]) / float64(requests)
+	}
+	return report, nil
+}
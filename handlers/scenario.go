@@ -0,0 +1,533 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/kubernetes"
+)
+
+// TrafficPhase is the phase type that runs the binning traffic test instead of an attack
+const TrafficPhase = "traffic"
+
+// phaseQueueSeconds lets a phase wait for the previous phase in its pods to be
+// stopped, so that back-to-back phases do not collide
+const phaseQueueSeconds = 2 * int(attackPollInterval/time.Second)
+
+// Scenario and phase states
+const (
+	scenarioPending   = "pending"
+	scenarioRunning   = "running"
+	scenarioCompleted = "completed"
+	scenarioFailed    = "failed"
+	scenarioStopped   = "stopped"
+)
+
+// ScenarioPhase is one step of a scenario timeline
+type ScenarioPhase struct {
+	Name               string       `json:"name"`
+	Type               string       `json:"type" binding:"required"` // "traffic" or a registered attack type
+	Pods               []string     `json:"pods" binding:"required"`
+	TargetIP           string       `json:"targetIP,omitempty"`
+	Params             AttackParams `json:"params"`
+	StartOffsetSeconds int          `json:"startOffsetSeconds"` // Delay from the start of the scenario
+	DurationSeconds    int          `json:"durationSeconds" binding:"required"`
+	Ephemeral          bool         `json:"ephemeral"`       // Run attacks in an ephemeral tools container
+	MonitorPod         string       `json:"monitorPod"`      // Also sample this pod's interfaces, e.g. the UPF
+	AllowConcurrent    bool         `json:"allowConcurrent"` // Share the pods with other attacks that allow it
+}
+
+// ScenarioRequest is the payload that starts a scenario
+type ScenarioRequest struct {
+	Name   string          `json:"name"`
+	Phases []ScenarioPhase `json:"phases" binding:"required"`
+}
+
+// phaseOutcome is the result of a phase in one pod
+type phaseOutcome struct {
+	Status string `json:"status"`
+	JobID  string `json:"jobId,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// phaseRun tracks the execution of a scenario phase
+type phaseRun struct {
+	ScenarioPhase
+	Status    string                   `json:"status"`
+	StartedAt *time.Time               `json:"startedAt,omitempty"`
+	EndedAt   *time.Time               `json:"endedAt,omitempty"`
+	Outcomes  map[string]*phaseOutcome `json:"outcomes"` // Keyed by pod name
+}
+
+// scenarioRun tracks the execution of a scenario
+type scenarioRun struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Status    string      `json:"status"`
+	StartedAt time.Time   `json:"startedAt"`
+	EndedAt   *time.Time  `json:"endedAt,omitempty"`
+	Phases    []*phaseRun `json:"phases"`
+
+	stop chan struct{} // Closed to abort the scenario
+}
+
+// scenarios holds every scenario started since the backend came up
+var scenarios = make(map[string]*scenarioRun)
+var scenariosMutex sync.Mutex
+
+// validateScenario checks the timeline before anything is launched
+func validateScenario(req *ScenarioRequest) error {
+	if len(req.Phases) == 0 {
+		return fmt.Errorf("a scenario needs at least one phase")
+	}
+	for i := range req.Phases {
+		phase := &req.Phases[i]
+		if phase.Name == "" {
+			phase.Name = fmt.Sprintf("phase-%d", i+1)
+		}
+		if len(phase.Pods) == 0 {
+			return fmt.Errorf("%s: pods must not be empty", phase.Name)
+		}
+		for _, pod := range phase.Pods {
+			if err := validatePodName("pods", pod); err != nil {
+				return fmt.Errorf("%s: %v", phase.Name, err)
+			}
+		}
+		if phase.StartOffsetSeconds < 0 || phase.StartOffsetSeconds > maxDuration {
+			return fmt.Errorf("%s: startOffsetSeconds must be between 0 and %d", phase.Name, maxDuration)
+		}
+		if phase.DurationSeconds <= 0 || phase.DurationSeconds > maxDuration {
+			return fmt.Errorf("%s: durationSeconds must be between 1 and %d", phase.Name, maxDuration)
+		}
+		if phase.Type == TrafficPhase {
+			if err := validateTrafficPhase(phase); err != nil {
+				return fmt.Errorf("%s: %v", phase.Name, err)
+			}
+			continue
+		}
+		attack, ok := LookupAttack(phase.Type)
+		if !ok {
+			return fmt.Errorf("%s: unknown phase type %q", phase.Name, phase.Type)
+		}
+		req := phase.attackRequest(phase.Pods[0])
+		if err := attack.normalize(&req); err != nil {
+			return fmt.Errorf("%s: %v", phase.Name, err)
+		}
+		phase.Params = req.Params
+	}
+	return nil
+}
+
+// validateTrafficPhase rejects the attack fields a traffic phase cannot honour
+func validateTrafficPhase(phase *ScenarioPhase) error {
+	var unsupported []string
+	if phase.TargetIP != "" {
+		unsupported = append(unsupported, "targetIP")
+	}
+	if set := phase.Params.set(); len(set) > 0 {
+		unsupported = append(unsupported, "params ("+strings.Join(set, ", ")+")")
+	}
+	if phase.Ephemeral {
+		unsupported = append(unsupported, "ephemeral")
+	}
+	if phase.MonitorPod != "" {
+		unsupported = append(unsupported, "monitorPod")
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("traffic phases do not support %s", strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// attackRequest builds the attack request a phase issues for one pod
+func (p *ScenarioPhase) attackRequest(podName string) AttackRequest {
+	return AttackRequest{
+		PodName:         podName,
+		TargetIP:        p.TargetIP,
+		Params:          p.Params,
+		DurationSeconds: p.DurationSeconds,
+		Ephemeral:       p.Ephemeral,
+		MonitorPod:      p.MonitorPod,
+		AllowConcurrent: p.AllowConcurrent,
+		QueueSeconds:    phaseQueueSeconds,
+	}
+}
+
+// startScenario registers a scenario and runs it in the background
+func startScenario(clientset *kubernetes.Clientset, req ScenarioRequest) *scenarioRun {
+	now := time.Now()
+	run := &scenarioRun{
+		ID:        "scenario-" + strconv.FormatInt(now.UnixNano(), 36),
+		Name:      req.Name,
+		Status:    scenarioRunning,
+		StartedAt: now,
+		stop:      make(chan struct{}),
+	}
+	for _, phase := range req.Phases {
+		outcomes := make(map[string]*phaseOutcome, len(phase.Pods))
+		for _, pod := range phase.Pods {
+			outcomes[pod] = &phaseOutcome{Status: scenarioPending}
+		}
+		run.Phases = append(run.Phases, &phaseRun{ScenarioPhase: phase, Status: scenarioPending, Outcomes: outcomes})
+	}
+
+	scenariosMutex.Lock()
+	scenarios[run.ID] = run
+	scenariosMutex.Unlock()
+
+	go executeScenario(clientset, run)
+	return run
+}
+
+// executeScenario starts every phase at its offset and waits for all of them
+func executeScenario(clientset *kubernetes.Clientset, run *scenarioRun) {
+	consoleLog("[SCENARIO] Starting scenario %s (%s) with %d phases\n", run.ID, run.Name, len(run.Phases))
+
+	var wg sync.WaitGroup
+	for _, phase := range run.Phases {
+		wg.Add(1)
+		go func(phase *phaseRun) {
+			defer wg.Done()
+			executePhase(clientset, run, phase)
+		}(phase)
+	}
+	wg.Wait()
+
+	scenariosMutex.Lock()
+	status := scenarioCompleted
+	for _, phase := range run.Phases {
+		if phase.Status == scenarioStopped {
+			status = scenarioStopped
+		} else if phase.Status == scenarioFailed && status != scenarioStopped {
+			status = scenarioFailed
+		}
+	}
+	now := time.Now()
+	run.Status = status
+	run.EndedAt = &now
+	scenariosMutex.Unlock()
+
+	consoleLog("[SCENARIO] Scenario %s finished: %s\n", run.ID, status)
+}
+
+// executePhase waits for the phase's offset, runs it in every pod and records
+// the outcome per pod
+func executePhase(clientset *kubernetes.Clientset, run *scenarioRun, phase *phaseRun) {
+	offset := time.NewTimer(time.Duration(phase.StartOffsetSeconds) * time.Second)
+	defer offset.Stop()
+	select {
+	case <-offset.C:
+	case <-run.stop:
+		setPhaseStatus(phase, scenarioStopped)
+		for _, outcome := range phase.Outcomes {
+			setOutcome(outcome, scenarioStopped, "", "")
+		}
+		return
+	}
+
+	consoleLog("[SCENARIO] %s: starting phase %s (%s) on %d pods\n", run.ID, phase.Name, phase.Type, len(phase.Pods))
+	setPhaseStatus(phase, scenarioRunning)
+
+	var wg sync.WaitGroup
+	for _, pod := range phase.Pods {
+		wg.Add(1)
+		go func(pod string) {
+			defer wg.Done()
+			outcome := phase.Outcomes[pod]
+			if phase.Type == TrafficPhase {
+				runTrafficPhase(clientset, run, phase, pod, outcome)
+			} else {
+				runAttackPhase(clientset, run, phase, pod, outcome)
+			}
+		}(pod)
+	}
+	wg.Wait()
+
+	status := scenarioCompleted
+	scenariosMutex.Lock()
+	for _, outcome := range phase.Outcomes {
+		if outcome.Status == scenarioStopped {
+			status = scenarioStopped
+		} else if outcome.Status != scenarioCompleted && status != scenarioStopped {
+			status = scenarioFailed
+		}
+	}
+	scenariosMutex.Unlock()
+	setPhaseStatus(phase, status)
+	consoleLog("[SCENARIO] %s: phase %s finished: %s\n", run.ID, phase.Name, status)
+}
+
+// runAttackPhase launches the phase's attack in one pod and waits until its
+// job ends or the scenario is stopped
+func runAttackPhase(clientset *kubernetes.Clientset, run *scenarioRun, phase *phaseRun, pod string, outcome *phaseOutcome) {
+	attack, _ := LookupAttack(phase.Type)
+	req := phase.attackRequest(pod)
+	setOutcome(outcome, scenarioRunning, "", "")
+
+	job, err := launchAttack(clientset, attack, req)
+	if err != nil {
+		setOutcome(outcome, scenarioFailed, "", err.Error())
+		return
+	}
+	setOutcome(outcome, scenarioRunning, job.ID, "")
+
+	select {
+	case <-job.done:
+	case <-run.stop:
+		unlock := lockPod(pod)
+		if finishAttackJob(job, exitStopped) {
+			stopAttack(clientset, attack, pod, job.Container)
+		}
+		unlock()
+	}
+
+	switch reason := job.snapshot().ExitReason; reason {
+	case exitCompleted:
+		setOutcome(outcome, scenarioCompleted, job.ID, "")
+	case exitStopped:
+		setOutcome(outcome, scenarioStopped, job.ID, "")
+	default:
+		setOutcome(outcome, scenarioFailed, job.ID, fmt.Sprintf("%s exited early (%s)", attack.Name, reason))
+	}
+}
+
+// runTrafficPhase runs the traffic test in one pod for the phase's duration.
+// It leases the pod like an attack phase, so it never runs alongside attacks
+// unless both allow it.
+func runTrafficPhase(clientset *kubernetes.Clientset, run *scenarioRun, phase *phaseRun, pod string, outcome *phaseOutcome) {
+	setOutcome(outcome, scenarioRunning, "", "")
+	jobID := newTrafficJobID()
+	queue := time.Duration(phaseQueueSeconds) * time.Second
+	if err := acquirePodLease(trafficLeaseAttack, pod, jobID, phase.AllowConcurrent, queue); err != nil {
+		setOutcome(outcome, scenarioFailed, "", err.Error())
+		return
+	}
+	defer releasePodLease(pod, jobID)
+
+	if err := prepareTrafficTest(clientset, pod); err != nil {
+		setOutcome(outcome, scenarioFailed, "", err.Error())
+		return
+	}
+
+	unlock := lockPod(pod)
+	if !holdsPodLease(pod, jobID) {
+		unlock()
+		setOutcome(outcome, scenarioFailed, "", "an emergency stop revoked the pod's lease while it was being prepared")
+		return
+	}
+	startTrafficJob(jobID, pod, phase.AllowConcurrent)
+	unlock()
+
+	finished := make(chan error, 1)
+	go func() {
+		_, err := runTrafficScript(clientset, pod)
+		finished <- err
+	}()
+
+	deadline := time.NewTimer(time.Duration(phase.DurationSeconds) * time.Second)
+	defer deadline.Stop()
+
+	select {
+	case err := <-finished:
+		// The traffic script ended before the phase did
+		if err != nil {
+			endJob(jobID, jobFailed, time.Now())
+			setOutcome(outcome, scenarioFailed, jobID, err.Error())
+		} else {
+			endJob(jobID, exitCompleted, time.Now())
+			setOutcome(outcome, scenarioCompleted, jobID, "")
+		}
+		return
+	case <-deadline.C:
+		endJob(jobID, exitCompleted, time.Now())
+		setOutcome(outcome, scenarioCompleted, jobID, "")
+	case <-run.stop:
+		setOutcome(outcome, scenarioStopped, jobID, "")
+	}
+
+	unlock = lockPod(pod)
+	defer unlock()
+	if _, err := stopTrafficTest(clientset, pod); err != nil && !noProcessFound(err) {
+		consoleLog("[SCENARIO] %s: failed to stop traffic in pod %s: %v\n", run.ID, pod, err)
+	}
+}
+
+func setPhaseStatus(phase *phaseRun, status string) {
+	scenariosMutex.Lock()
+	defer scenariosMutex.Unlock()
+	now := time.Now()
+	if status == scenarioRunning {
+		phase.StartedAt = &now
+	} else if status != scenarioPending {
+		phase.EndedAt = &now
+	}
+	phase.Status = status
+}
+
+func setOutcome(outcome *phaseOutcome, status, jobID, message string) {
+	scenariosMutex.Lock()
+	defer scenariosMutex.Unlock()
+	outcome.Status = status
+	if jobID != "" {
+		outcome.JobID = jobID
+	}
+	outcome.Error = message
+}
+
+// scenarioView renders a scenario with its progress for JSON responses
+func scenarioView(run *scenarioRun) gin.H {
+	scenariosMutex.Lock()
+	defer scenariosMutex.Unlock()
+
+	finished := 0
+	var current []string
+	phases := make([]phaseRun, 0, len(run.Phases))
+	for _, phase := range run.Phases {
+		switch phase.Status {
+		case scenarioRunning:
+			current = append(current, phase.Name)
+		case scenarioCompleted, scenarioFailed, scenarioStopped:
+			finished++
+		}
+		copied := *phase
+		copied.Outcomes = make(map[string]*phaseOutcome, len(phase.Outcomes))
+		for pod, outcome := range phase.Outcomes {
+			o := *outcome
+			copied.Outcomes[pod] = &o
+		}
+		phases = append(phases, copied)
+	}
+
+	view := gin.H{
+		"id":             run.ID,
+		"name":           run.Name,
+		"status":         run.Status,
+		"startedAt":      run.StartedAt,
+		"phases":         phases,
+		"currentPhases":  current,
+		"finishedPhases": finished,
+		"totalPhases":    len(run.Phases),
+		"progress":       float64(finished) / float64(len(run.Phases)),
+	}
+	if run.EndedAt != nil {
+		view["endedAt"] = *run.EndedAt
+	}
+	return view
+}
+
+// lookupScenario resolves the scenario named by the :id route parameter
+func lookupScenario(c *gin.Context) (*scenarioRun, bool) {
+	scenariosMutex.Lock()
+	run, ok := scenarios[c.Param("id")]
+	scenariosMutex.Unlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Unknown scenario: %s", c.Param("id"))})
+	}
+	return run, ok
+}
+
+// StartScenario validates a scenario timeline and starts executing it
+func StartScenario(clientset *kubernetes.Clientset) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ScenarioRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request parameters"})
+			return
+		}
+		if err := validateScenario(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		run := startScenario(clientset, req)
+		c.JSON(http.StatusOK, scenarioView(run))
+	}
+}
+
+// ListScenarios returns every scenario with its progress, newest first
+func ListScenarios() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scenariosMutex.Lock()
+		runs := make([]*scenarioRun, 0, len(scenarios))
+		for _, run := range scenarios {
+			runs = append(runs, run)
+		}
+		scenariosMutex.Unlock()
+		sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+
+		views := make([]gin.H, 0, len(runs))
+		for _, run := range runs {
+			views = append(views, scenarioView(run))
+		}
+		c.JSON(http.StatusOK, gin.H{"scenarios": views})
+	}
+}
+
+// GetScenario returns the progress of one scenario
+func GetScenario() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if run, ok := lookupScenario(c); ok {
+			c.JSON(http.StatusOK, scenarioView(run))
+		}
+	}
+}
+
+// stopScenarioRun signals a scenario to stop its active phases. It reports
+// false when the scenario had already ended or been stopped.
+func stopScenarioRun(run *scenarioRun) bool {
+	scenariosMutex.Lock()
+	defer scenariosMutex.Unlock()
+	if run.EndedAt != nil {
+		return false
+	}
+	select {
+	case <-run.stop:
+		return false
+	default:
+		close(run.stop)
+		return true
+	}
+}
+
+// stopAllScenarios stops every running scenario and returns their IDs
+func stopAllScenarios() []string {
+	scenariosMutex.Lock()
+	runs := make([]*scenarioRun, 0, len(scenarios))
+	for _, run := range scenarios {
+		runs = append(runs, run)
+	}
+	scenariosMutex.Unlock()
+
+	stopped := []string{}
+	for _, run := range runs {
+		if stopScenarioRun(run) {
+			consoleLog("[SCENARIO] Stopping scenario %s\n", run.ID)
+			stopped = append(stopped, run.ID)
+		}
+	}
+	sort.Strings(stopped)
+	return stopped
+}
+
+// StopScenario aborts a running scenario, stopping its active phases
+func StopScenario() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		run, ok := lookupScenario(c)
+		if !ok {
+			return
+		}
+
+		if !stopScenarioRun(run) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Scenario %s is not running", run.ID)})
+			return
+		}
+		consoleLog("[SCENARIO] Stopping scenario %s\n", run.ID)
+		c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("Scenario %s is stopping", run.ID)})
+	}
+}
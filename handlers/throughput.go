@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// throughputInterval is how often interface counters are sampled while a job runs
+	throughputInterval = 5 * time.Second
+	// maxThroughputSamples bounds the history kept per job, one hour per interface at the default interval
+	maxThroughputSamples = 720
+	// statusThroughputSamples is how much of the history status responses carry
+	statusThroughputSamples = 60
+)
+
+// interfaceCounters are the cumulative counters of /sys/class/net/<iface>/statistics
+type interfaceCounters struct {
+	RxPackets, RxBytes, RxErrors, RxDropped uint64
+	TxPackets, TxBytes, TxErrors, TxDropped uint64
+}
+
+// throughputSample holds the rates of one interface between two readings
+type throughputSample struct {
+	Time            time.Time `json:"time"`
+	Pod             string    `json:"pod"`
+	Interface       string    `json:"interface"`
+	TxPacketsPerSec float64   `json:"txPacketsPerSec"`
+	TxBytesPerSec   float64   `json:"txBytesPerSec"`
+	RxPacketsPerSec float64   `json:"rxPacketsPerSec"`
+	RxBytesPerSec   float64   `json:"rxBytesPerSec"`
+	ErrorsPerSec    float64   `json:"errorsPerSec"` // rx and tx errors
+	DropsPerSec     float64   `json:"dropsPerSec"`  // rx and tx drops
+}
+
+// throughputSummary aggregates the samples of one interface over a job
+type throughputSummary struct {
+	Pod                 string  `json:"pod"`
+	Interface           string  `json:"interface"`
+	Samples             int     `json:"samples"`
+	AvgTxPacketsPerSec  float64 `json:"avgTxPacketsPerSec"`
+	PeakTxPacketsPerSec float64 `json:"peakTxPacketsPerSec"`
+	AvgTxBytesPerSec    float64 `json:"avgTxBytesPerSec"`
+	AvgRxPacketsPerSec  float64 `json:"avgRxPacketsPerSec"`
+	AvgRxBytesPerSec    float64 `json:"avgRxBytesPerSec"`
+	AvgErrorsPerSec     float64 `json:"avgErrorsPerSec"`
+	AvgDropsPerSec      float64 `json:"avgDropsPerSec"`
+}
+
+// throughputMonitor keeps the samples taken while a job runs
+type throughputMonitor struct {
+	mu      sync.Mutex
+	samples []throughputSample
+}
+
+func (m *throughputMonitor) add(sample throughputSample) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, sample)
+	if len(m.samples) > maxThroughputSamples {
+		m.samples = m.samples[len(m.samples)-maxThroughputSamples:]
+	}
+}
+
+// recent returns up to n of the latest samples
+func (m *throughputMonitor) recent(n int) []throughputSample {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.samples) > n {
+		return append([]throughputSample(nil), m.samples[len(m.samples)-n:]...)
+	}
+	return append([]throughputSample(nil), m.samples...)
+}
+
+// summary aggregates the samples per pod and interface
+func (m *throughputMonitor) summary() []throughputSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var summaries []throughputSummary
+	index := make(map[string]int)
+	for _, s := range m.samples {
+		key := s.Pod + "/" + s.Interface
+		i, ok := index[key]
+		if !ok {
+			i = len(summaries)
+			index[key] = i
+			summaries = append(summaries, throughputSummary{Pod: s.Pod, Interface: s.Interface})
+		}
+		sum := &summaries[i]
+		sum.Samples++
+		sum.AvgTxPacketsPerSec += s.TxPacketsPerSec
+		sum.AvgTxBytesPerSec += s.TxBytesPerSec
+		sum.AvgRxPacketsPerSec += s.RxPacketsPerSec
+		sum.AvgRxBytesPerSec += s.RxBytesPerSec
+		sum.AvgErrorsPerSec += s.ErrorsPerSec
+		sum.AvgDropsPerSec += s.DropsPerSec
+		if s.TxPacketsPerSec > sum.PeakTxPacketsPerSec {
+			sum.PeakTxPacketsPerSec = s.TxPacketsPerSec
+		}
+	}
+	for i := range summaries {
+		n := float64(summaries[i].Samples)
+		summaries[i].AvgTxPacketsPerSec /= n
+		summaries[i].AvgTxBytesPerSec /= n
+		summaries[i].AvgRxPacketsPerSec /= n
+		summaries[i].AvgRxBytesPerSec /= n
+		summaries[i].AvgErrorsPerSec /= n
+		summaries[i].AvgDropsPerSec /= n
+	}
+	return summaries
+}
+
+// status describes the measured throughput for status responses
+func (m *throughputMonitor) status() map[string]interface{} {
+	return map[string]interface{}{
+		"intervalSeconds": int(throughputInterval / time.Second),
+		"summary":         m.summary(),
+		"samples":         m.recent(statusThroughputSamples),
+	}
+}
+
+// counterTarget is a pod whose interfaces are sampled. No interfaces means
+// every interface but the loopback.
+type counterTarget struct {
+	Pod        string
+	Container  string
+	Interfaces []string
+}
+
+// monitorThroughput samples the interface counters of the attacking pod's
+// tunnel, or of its primary interface for attacks sent over the pod network,
+// and of the job's monitor pod if any, until the job ends. The summary is then
+// saved with the job.
+func monitorThroughput(clientset *kubernetes.Clientset, job *attackJob) {
+	iface := tunnelInterface
+	if job.Attack.PodNetwork {
+		iface = podInterface
+	}
+	targets := []counterTarget{{Pod: job.PodName, Container: job.Container, Interfaces: []string{iface}}}
+	if job.MonitorPod != "" {
+		targets = append(targets, counterTarget{Pod: job.MonitorPod})
+	}
+
+	ticker := time.NewTicker(throughputInterval)
+	defer ticker.Stop()
+
+	type reading struct {
+		at       time.Time
+		counters interfaceCounters
+	}
+	previous := make(map[string]reading)
+	failed := make(map[string]bool) // Log a failing pod once, not on every tick
+
+	for {
+		select {
+		case <-job.done:
+			if summary := job.throughput.summary(); len(summary) > 0 {
+				setJobThroughput(job.ID, summary)
+			}
+			return
+
+		case <-ticker.C:
+			for _, target := range targets {
+				counters, err := readInterfaceCounters(clientset, target)
+				if err != nil {
+					if !failed[target.Pod] {
+						consoleLog("[%s] Failed to sample interfaces of pod %s for job %s: %v\n", job.Attack.LogTag, target.Pod, job.ID, err)
+						failed[target.Pod] = true
+					}
+					continue
+				}
+				failed[target.Pod] = false
+
+				now := time.Now()
+				for iface, current := range counters {
+					key := target.Pod + "/" + iface
+					last, ok := previous[key]
+					previous[key] = reading{now, current}
+					if !ok {
+						continue
+					}
+					if sample, ok := rates(last.counters, current, now.Sub(last.at)); ok {
+						sample.Time, sample.Pod, sample.Interface = now, target.Pod, iface
+						job.throughput.add(sample)
+					}
+				}
+			}
+		}
+	}
+}
+
+// rates turns two readings into per-second rates. A counter going backwards,
+// e.g. because the interface was recreated, invalidates the sample.
+func rates(last, current interfaceCounters, elapsed time.Duration) (throughputSample, bool) {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		return throughputSample{}, false
+	}
+	ok := true
+	delta := func(a, b uint64) float64 {
+		if b < a {
+			ok = false
+			return 0
+		}
+		return float64(b-a) / seconds
+	}
+	sample := throughputSample{
+		TxPacketsPerSec: delta(last.TxPackets, current.TxPackets),
+		TxBytesPerSec:   delta(last.TxBytes, current.TxBytes),
+		RxPacketsPerSec: delta(last.RxPackets, current.RxPackets),
+		RxBytesPerSec:   delta(last.RxBytes, current.RxBytes),
+		ErrorsPerSec:    delta(last.RxErrors, current.RxErrors) + delta(last.TxErrors, current.TxErrors),
+		DropsPerSec:     delta(last.RxDropped, current.RxDropped) + delta(last.TxDropped, current.TxDropped),
+	}
+	return sample, ok
+}
+
+// readInterfaceCounters reads the statistics of the target's interfaces in
+// one exec
+func readInterfaceCounters(clientset *kubernetes.Clientset, target counterTarget) (map[string]interfaceCounters, error) {
+	script := `cd /sys/class/net || exit 1
+[ $# -gt 0 ] || set -- *
+for i in "$@"; do
+  [ "$i" = lo ] && continue
+  s=$i/statistics
+  [ -d "$s" ] || continue
+  echo "$i" $(cat $s/rx_packets $s/rx_bytes $s/rx_errors $s/rx_dropped $s/tx_packets $s/tx_bytes $s/tx_errors $s/tx_dropped)
+done`
+	result, err := containerExec(clientset, target.Pod, target.Container, append([]string{"sh", "-c", script, "sh"}, target.Interfaces...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make(map[string]interfaceCounters)
+	for _, line := range strings.Split(strings.TrimSpace(result.Stdout), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 9 {
+			continue
+		}
+		var values [8]uint64
+		for i := range values {
+			if values[i], err = strconv.ParseUint(fields[i+1], 10, 64); err != nil {
+				return nil, fmt.Errorf("unexpected counter %q for %s", fields[i+1], fields[0])
+			}
+		}
+		counters[fields[0]] = interfaceCounters{
+			RxPackets: values[0], RxBytes: values[1], RxErrors: values[2], RxDropped: values[3],
+			TxPackets: values[4], TxBytes: values[5], TxErrors: values[6], TxDropped: values[7],
+		}
+	}
+	if len(counters) == 0 {
+		return nil, fmt.Errorf("no interface statistics found")
+	}
+	return counters, nil
+}
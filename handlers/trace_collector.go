@@ -15,6 +15,8 @@ import (
 
 	"context"
 
+	"k8s-status-api/k8s"
+
 	"github.com/gin-gonic/gin"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -35,6 +37,10 @@ type TraceCollectorConfig struct {
 	CICFlowMeterPath     string
 }
 
+// traceCopyTimeout bounds copying one trace file out of the collector, which
+// can be far larger than the output of defaultExecTimeout commands
+const traceCopyTimeout = 10 * time.Minute
+
 // Global configuration for trace collector
 var traceConfig = TraceCollectorConfig{
 	Namespace:            "default",
@@ -126,20 +132,27 @@ func StartTraceCollector(clientset *kubernetes.Clientset) gin.HandlerFunc {
 	}
 }
 
+// stopTraceCollector signals the collector to stop, reporting false when it
+// was not running
+func stopTraceCollector() bool {
+	if !traceConfig.IsRunning {
+		return false
+	}
+	close(traceConfig.StopChan)
+	traceConfig.IsRunning = false
+	return true
+}
+
 // StopTraceCollector stops the trace collector
 func StopTraceCollector() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if !traceConfig.IsRunning {
+		if !stopTraceCollector() {
 			c.JSON(http.StatusOK, gin.H{
 				"message": "Trace collector is not running",
 			})
 			return
 		}
 
-		// Signal the collector to stop
-		close(traceConfig.StopChan)
-		traceConfig.IsRunning = false
-
 		c.JSON(http.StatusOK, gin.H{
 			"message": "Trace collector stopped successfully",
 		})
@@ -176,6 +189,18 @@ func ConfigureTraceCollector() gin.HandlerFunc {
 			})
 			return
 		}
+		if config.Namespace != "" {
+			if err := validateDNSLabel("Namespace", config.Namespace); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if config.ContainerName != "" {
+			if err := validateDNSLabel("ContainerName", config.ContainerName); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
 
 		// Update the configuration (only non-empty fields)
 		if config.Namespace != "" {
@@ -447,7 +472,7 @@ func collectTraces(clientset *kubernetes.Clientset, stopChan <-chan struct{}) {
 
 	// Install CICFlowMeter dependencies if needed
 	if err := setupCICFlowMeter(consoleLog); err != nil {
-		consoleLog("[TRACE-ERROR] Failed to setup CICFlowMeter: %v\n")
+		consoleLog("[TRACE-ERROR] Failed to setup CICFlowMeter: %v\n", err)
 	}
 
 	for {
@@ -469,19 +494,19 @@ func collectTraces(clientset *kubernetes.Clientset, stopChan <-chan struct{}) {
 			consoleLog("[TRACE] Listing trace files in pod %s (namespace: %s)...\n",
 				podName, traceConfig.Namespace)
 
-			cmd := exec.Command(
-				"kubectl", "exec", "-n", traceConfig.Namespace, podName,
-				"-c", traceConfig.ContainerName, "--", "ls", "-1", traceConfig.DestinationPath,
-			)
-
-			output, err := cmd.CombinedOutput()
+			listing, err := k8s.Exec(context.Background(), clientset, podName, k8s.ExecOptions{
+				Namespace: traceConfig.Namespace,
+				Container: traceConfig.ContainerName,
+				Command:   []string{"ls", "-1", traceConfig.DestinationPath},
+				Timeout:   defaultExecTimeout,
+			})
 			if err != nil {
-				consoleLog("[TRACE-ERROR] Failed to list files in pod: %v\nOutput: %s\n", err, output)
+				consoleLog("[TRACE-ERROR] Failed to list files in pod: %v\n", err)
 				continue
 			}
 
 			// Parse the output to get trace files
-			traceFiles := strings.Split(strings.TrimSpace(string(output)), "\n")
+			traceFiles := strings.Split(strings.TrimSpace(listing.Stdout), "\n")
 
 			// Filter for .pcap files and remove empty entries
 			var pcapFiles []string
@@ -520,18 +545,11 @@ func collectTraces(clientset *kubernetes.Clientset, stopChan <-chan struct{}) {
 				}
 
 				// Copy the file
-				remotePath := fmt.Sprintf("%s:%s/%s", podName, traceConfig.DestinationPath, traceFile)
-				consoleLog("[TRACE] Copying %s to %s...\n", remotePath, localPath)
-
-				copyCmd := exec.Command(
-					"kubectl", "cp", "-n", traceConfig.Namespace,
-					"-c", traceConfig.ContainerName,
-					remotePath, localPath,
-				)
-
-				if copyOutput, err := copyCmd.CombinedOutput(); err != nil {
-					consoleLog("[TRACE-ERROR] Error copying %s: %v\nOutput: %s\n",
-						traceFile, err, copyOutput)
+				remotePath := fmt.Sprintf("%s/%s", traceConfig.DestinationPath, traceFile)
+				consoleLog("[TRACE] Copying %s:%s to %s...\n", podName, remotePath, localPath)
+
+				if err := copyTraceFile(clientset, podName, remotePath, localPath); err != nil {
+					consoleLog("[TRACE-ERROR] Error copying %s: %v\n", traceFile, err)
 				} else {
 					consoleLog("[TRACE] Successfully copied %s\n", traceFile)
 					filesCopied++
@@ -565,6 +583,28 @@ func collectTraces(clientset *kubernetes.Clientset, stopChan <-chan struct{}) {
 	}
 }
 
+// copyTraceFile streams a trace file out of the collector container, removing
+// the partial local file if the transfer fails
+func copyTraceFile(clientset *kubernetes.Clientset, podName, remotePath, localPath string) error {
+	f, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+
+	err = k8s.CopyFromPod(context.Background(), clientset, podName, k8s.ExecOptions{
+		Namespace: traceConfig.Namespace,
+		Container: traceConfig.ContainerName,
+		Timeout:   traceCopyTimeout,
+	}, remotePath, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(localPath)
+	}
+	return err
+}
+
 // setupCICFlowMeter ensures the environment is ready to run the pre-built CICFlowMeter.
 func setupCICFlowMeter(consoleLog func(format string, args ...interface{})) error {
 	consoleLog("[TRACE] Verifying pre-built CICFlowMeter setup...\n")
@@ -598,7 +638,7 @@ func setupCICFlowMeter(consoleLog func(format string, args ...interface{})) erro
 	if err := os.Chmod(cfmExecutablePath, 0755); err != nil {
 		consoleLog("[TRACE-WARNING] Failed to make CICFlowMeter executable at %s (may proceed if already executable): %v\n", cfmExecutablePath, err)
 	} else {
-		consoleLog("[TRACE] CICFlowMeter executable at %s is now executable.\n")
+		consoleLog("[TRACE] CICFlowMeter executable at %s is now executable.\n", cfmExecutablePath)
 	}
 
 	// Path for the new wrapper script that generateFlowSessions will call
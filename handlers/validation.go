@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Request fields end up in pod exec commands, launcher scripts and helm
+// arguments, so they are checked strictly before anything is run. Every
+// failed check is answered with 400.
+
+// maxReleaseName is the longest release name helm accepts
+const maxReleaseName = 53
+
+// msisdnPattern matches the digits of an MSISDN
+var msisdnPattern = regexp.MustCompile(`^[0-9]{1,15}$`)
+
+// targetAllowlist holds the testbed networks attack targets must fall in.
+// Empty refuses every target; allowing any address takes 0.0.0.0/0 and ::/0.
+var targetAllowlist []netip.Prefix
+
+// SetTargetAllowlist configures the networks attack targets must fall in
+func SetTargetAllowlist(cidrs []string) error {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(cidr))
+		if err != nil {
+			return fmt.Errorf("invalid target allowlist entry %q: %v", cidr, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	targetAllowlist = prefixes
+	return nil
+}
+
+// AllowsAnyTarget reports whether the allowlist covers every IPv4 or IPv6 address
+func AllowsAnyTarget() bool {
+	for _, prefix := range targetAllowlist {
+		if prefix.Bits() == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedTargets lists the allowlist for error messages
+func allowedTargets() string {
+	cidrs := make([]string, len(targetAllowlist))
+	for i, prefix := range targetAllowlist {
+		cidrs[i] = prefix.String()
+	}
+	return strings.Join(cidrs, ", ")
+}
+
+// validateTargetIP checks that the target is a literal IPv4 or IPv6 address
+// inside the target allowlist
+func validateTargetIP(target string) error {
+	addr, err := netip.ParseAddr(target)
+	if err != nil || addr.Zone() != "" {
+		return fmt.Errorf("targetIP %q is not a valid IP address", target)
+	}
+	if len(targetAllowlist) == 0 {
+		return fmt.Errorf("targetIP %s is refused, no target networks are allowed", target)
+	}
+	addr = addr.Unmap()
+	for _, prefix := range targetAllowlist {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+	return fmt.Errorf("targetIP %s is outside the allowed target networks (%s)", target, allowedTargets())
+}
+
+// validatePodName checks that a request field names a pod as Kubernetes
+// requires, a DNS subdomain
+func validatePodName(field, name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("%s %q is not a valid pod name: %s", field, name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// validateDNSLabel checks that a request field is a DNS label, as namespace,
+// container and release names must be
+func validateDNSLabel(field, name string) error {
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return fmt.Errorf("%s %q is not a valid name: %s", field, name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// validateReleaseName checks a helm release name. A name starting with a dash
+// would otherwise be read by helm as a flag.
+func validateReleaseName(field, name string) error {
+	if err := validateDNSLabel(field, name); err != nil {
+		return err
+	}
+	if len(name) > maxReleaseName {
+		return fmt.Errorf("%s %q is longer than %d characters", field, name, maxReleaseName)
+	}
+	return nil
+}
+
+// validateMSISDN checks that an MSISDN is made of digits only
+func validateMSISDN(field, msisdn string) error {
+	if !msisdnPattern.MatchString(msisdn) {
+		return fmt.Errorf("%s %q must be 1 to 15 digits", field, msisdn)
+	}
+	return nil
+}
@@ -8,6 +8,10 @@ import (
 	"k8s.io/client-go/tools/clientcmd"
 )
 
+// restConfig is the configuration the client was built from, kept for the
+// streaming exec connections that cannot go through the typed clientset
+var restConfig *rest.Config
+
 // GetKubeClient initializes and returns a Kubernetes client
 func GetKubeClient() (*kubernetes.Clientset, error) {
 	var config *rest.Config
@@ -33,5 +37,6 @@ func GetKubeClient() (*kubernetes.Clientset, error) {
 	if err != nil {
 		return nil, err
 	}
+	restConfig = config
 	return clientset, nil
 } 
\ No newline at end of file
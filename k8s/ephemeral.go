@@ -0,0 +1,117 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// EphemeralOptions describes an ephemeral container injected into a pod
+type EphemeralOptions struct {
+	Namespace       string   // Defaults to DefaultNamespace
+	NamePrefix      string   // Containers are named <prefix>-<suffix>
+	Image           string   // Image with the tools preinstalled
+	TargetContainer string   // Container whose process namespace is shared, defaults to the pod's default container
+	Capabilities    []string // Extra Linux capabilities, e.g. NET_ADMIN and NET_RAW for raw sockets
+	StartTimeout    time.Duration
+}
+
+// EnsureEphemeralContainer returns a running ephemeral container with the
+// given prefix and image, adding one through the pod's ephemeralcontainers
+// subresource when none is running. Ephemeral containers share the pod's
+// network namespace, so they see the same interfaces as the UE container.
+// They cannot be removed or restarted, so a container that has exited is
+// replaced by a new one with a different name.
+func EnsureEphemeralContainer(ctx context.Context, clientset *kubernetes.Clientset, podName string, opts EphemeralOptions) (string, error) {
+	if opts.Namespace == "" {
+		opts.Namespace = DefaultNamespace
+	}
+	if opts.StartTimeout == 0 {
+		opts.StartTimeout = 5 * time.Minute
+	}
+	pods := clientset.CoreV1().Pods(opts.Namespace)
+
+	pod, err := pods.Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get pod %s/%s: %v", opts.Namespace, podName, err)
+	}
+	if name := runningEphemeral(pod, opts.NamePrefix, opts.Image); name != "" {
+		return name, nil
+	}
+
+	if opts.TargetContainer == "" {
+		if name := pod.Annotations[defaultContainerAnnotation]; name != "" {
+			opts.TargetContainer = name
+		} else if len(pod.Spec.Containers) > 0 {
+			opts.TargetContainer = pod.Spec.Containers[0].Name
+		}
+	}
+
+	var capabilities []corev1.Capability
+	for _, capability := range opts.Capabilities {
+		capabilities = append(capabilities, corev1.Capability(capability))
+	}
+	name := opts.NamePrefix + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:    name,
+			Image:   opts.Image,
+			Command: []string{"sleep", "infinity"},
+			SecurityContext: &corev1.SecurityContext{
+				Capabilities: &corev1.Capabilities{Add: capabilities},
+			},
+		},
+		TargetContainerName: opts.TargetContainer,
+	})
+	if _, err := pods.UpdateEphemeralContainers(ctx, podName, pod, metav1.UpdateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to add ephemeral container to pod %s/%s: %v", opts.Namespace, podName, err)
+	}
+
+	// Wait for the kubelet to pull the image and start the container
+	err = wait.PollUntilContextTimeout(ctx, 2*time.Second, opts.StartTimeout, true, func(ctx context.Context) (bool, error) {
+		pod, err := pods.Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, status := range pod.Status.EphemeralContainerStatuses {
+			if status.Name != name {
+				continue
+			}
+			if status.State.Running != nil {
+				return true, nil
+			}
+			if terminated := status.State.Terminated; terminated != nil {
+				return false, fmt.Errorf("ephemeral container %s exited: %s", name, terminated.Reason)
+			}
+			if waiting := status.State.Waiting; waiting != nil && strings.Contains(waiting.Reason, "ImagePull") {
+				return false, fmt.Errorf("ephemeral container %s cannot pull %s: %s", name, opts.Image, waiting.Message)
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// runningEphemeral returns a running ephemeral container with the prefix and image
+func runningEphemeral(pod *corev1.Pod, prefix, image string) string {
+	images := make(map[string]string)
+	for _, container := range pod.Spec.EphemeralContainers {
+		images[container.Name] = container.Image
+	}
+	for _, status := range pod.Status.EphemeralContainerStatuses {
+		if strings.HasPrefix(status.Name, prefix+"-") && images[status.Name] == image && status.State.Running != nil {
+			return status.Name
+		}
+	}
+	return ""
+}
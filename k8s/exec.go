@@ -0,0 +1,216 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// DefaultNamespace is used when an exec request does not name a namespace
+const DefaultNamespace = "default"
+
+// defaultContainerAnnotation selects the container kubectl exec would pick
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// ExecOptions selects where and how a command runs inside a pod
+type ExecOptions struct {
+	Namespace string        // Defaults to DefaultNamespace
+	Container string        // Defaults to the pod's default container
+	Command   []string      // Command and arguments, run without a shell
+	Stdin     io.Reader     // Optional input streamed to the command
+	Stdout    io.Writer     // Optional writer receiving stdout instead of ExecResult.Stdout
+	Timeout   time.Duration // Optional limit on top of the caller's context
+}
+
+// ExecResult holds the separated output and exit code of a finished command
+type ExecResult struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exitCode"`
+}
+
+// ExecError is returned when a command could not be run or exited non-zero
+type ExecError struct {
+	Namespace string
+	Pod       string
+	Container string
+	Command   []string
+	ExitCode  int // -1 when the command did not run to completion
+	Stdout    string
+	Stderr    string
+	Err       error
+}
+
+func (e *ExecError) Error() string {
+	if e.ExitCode > 0 {
+		return fmt.Sprintf("command %q in pod %s/%s exited with code %d: %s",
+			strings.Join(e.Command, " "), e.Namespace, e.Pod, e.ExitCode, strings.TrimSpace(e.Stderr))
+	}
+	return fmt.Sprintf("command %q in pod %s/%s failed: %v",
+		strings.Join(e.Command, " "), e.Namespace, e.Pod, e.Err)
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// Details returns the error in a form suitable for JSON responses
+func (e *ExecError) Details() map[string]interface{} {
+	details := map[string]interface{}{
+		"pod":      e.Pod,
+		"command":  e.Command,
+		"exitCode": e.ExitCode,
+		"stdout":   e.Stdout,
+		"stderr":   e.Stderr,
+	}
+	if e.Err != nil {
+		details["cause"] = e.Err.Error()
+	}
+	return details
+}
+
+// ExitCode returns the exit code carried by an ExecError, or -1
+func ExitCode(err error) int {
+	var execErr *ExecError
+	if errors.As(err, &execErr) {
+		return execErr.ExitCode
+	}
+	return -1
+}
+
+// Exec runs a command inside a pod through the API server, using WebSockets
+// and falling back to SPDY for API servers that do not support them
+func Exec(ctx context.Context, clientset *kubernetes.Clientset, podName string, opts ExecOptions) (*ExecResult, error) {
+	if restConfig == nil {
+		return nil, fmt.Errorf("kubernetes client has not been initialized")
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = DefaultNamespace
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	execErr := &ExecError{
+		Namespace: opts.Namespace,
+		Pod:       podName,
+		Command:   opts.Command,
+		ExitCode:  -1,
+	}
+
+	if opts.Container == "" {
+		container, err := defaultContainer(ctx, clientset, opts.Namespace, podName)
+		if err != nil {
+			execErr.Err = err
+			return nil, execErr
+		}
+		opts.Container = container
+	}
+	execErr.Container = opts.Container
+
+	req := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(opts.Namespace).
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: opts.Container,
+			Command:   opts.Command,
+			Stdin:     opts.Stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := newExecutor(req.URL())
+	if err != nil {
+		execErr.Err = err
+		return nil, execErr
+	}
+
+	var stdout, stderr bytes.Buffer
+	var stdoutWriter io.Writer = &stdout
+	if opts.Stdout != nil {
+		stdoutWriter = opts.Stdout
+	}
+
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  opts.Stdin,
+		Stdout: stdoutWriter,
+		Stderr: &stderr,
+	})
+
+	result := &ExecResult{Stdout: stdout.String(), Stderr: stderr.String()}
+	if err != nil {
+		var exitErr utilexec.ExitError
+		if errors.As(err, &exitErr) && exitErr.Exited() {
+			result.ExitCode = exitErr.ExitStatus()
+			execErr.ExitCode = result.ExitCode
+		} else {
+			result.ExitCode = -1
+		}
+		execErr.Stdout = result.Stdout
+		execErr.Stderr = result.Stderr
+		execErr.Err = err
+		return result, execErr
+	}
+	return result, nil
+}
+
+// newExecutor builds a WebSocket executor with an SPDY fallback
+func newExecutor(execURL *url.URL) (remotecommand.Executor, error) {
+	spdyExec, err := remotecommand.NewSPDYExecutor(restConfig, "POST", execURL)
+	if err != nil {
+		return nil, err
+	}
+	wsExec, err := remotecommand.NewWebSocketExecutor(restConfig, "GET", execURL.String())
+	if err != nil {
+		return nil, err
+	}
+	return remotecommand.NewFallbackExecutor(wsExec, spdyExec, httpstream.IsUpgradeFailure)
+}
+
+// defaultContainer resolves the container kubectl would exec into
+func defaultContainer(ctx context.Context, clientset *kubernetes.Clientset, namespace, podName string) (string, error) {
+	pod, err := clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get pod %s/%s: %v", namespace, podName, err)
+	}
+	if name := pod.Annotations[defaultContainerAnnotation]; name != "" {
+		return name, nil
+	}
+	if len(pod.Spec.Containers) == 0 {
+		return "", fmt.Errorf("pod %s/%s has no containers", namespace, podName)
+	}
+	return pod.Spec.Containers[0].Name, nil
+}
+
+// CopyToPod streams src into remotePath inside the pod
+func CopyToPod(ctx context.Context, clientset *kubernetes.Clientset, podName string, opts ExecOptions, src io.Reader, remotePath string) error {
+	opts.Command = []string{"sh", "-c", `cat > "$0"`, remotePath}
+	opts.Stdin = src
+	_, err := Exec(ctx, clientset, podName, opts)
+	return err
+}
+
+// CopyFromPod streams remotePath from the pod into dst
+func CopyFromPod(ctx context.Context, clientset *kubernetes.Clientset, podName string, opts ExecOptions, remotePath string, dst io.Writer) error {
+	opts.Command = []string{"cat", remotePath}
+	opts.Stdout = dst
+	_, err := Exec(ctx, clientset, podName, opts)
+	return err
+}
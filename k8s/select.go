@@ -0,0 +1,44 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SelectPods returns the sorted names of the running pods in namespace that
+// match the label selector and the name pattern, a glob such as
+// "ueransim-ue*". Empty filters match every pod.
+func SelectPods(ctx context.Context, clientset *kubernetes.Clientset, namespace, labelSelector, namePattern string) ([]string, error) {
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	if _, err := path.Match(namePattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pod name pattern %q: %v", namePattern, err)
+	}
+
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+			continue
+		}
+		if namePattern != "" {
+			if matched, _ := path.Match(namePattern, pod.Name); !matched {
+				continue
+			}
+		}
+		names = append(names, pod.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
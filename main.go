@@ -8,8 +8,11 @@ import (
 	"os"
 	"time"
 
+	"k8s-status-api/auth"
+	"k8s-status-api/config"
 	"k8s-status-api/handlers"
 	"k8s-status-api/k8s"
+	"k8s-status-api/scripts"
 
 	"github.com/gin-gonic/gin"
 )
@@ -27,6 +30,26 @@ func (fw *FlushWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
+// newAuthenticator builds the request authenticator from the auth settings
+func newAuthenticator(cfg config.AuthConfig) (*auth.Authenticator, error) {
+	keys := make([]auth.APIKey, 0, len(cfg.APIKeys))
+	for _, key := range cfg.APIKeys {
+		role, err := auth.ParseRole(key.Role)
+		if err != nil {
+			return nil, fmt.Errorf("API key %q: %v", key.Name, err)
+		}
+		keys = append(keys, auth.APIKey{Name: key.Name, Key: key.Key, Role: role})
+	}
+	return auth.New(auth.Config{
+		Disabled:  cfg.Disabled,
+		APIKeys:   keys,
+		JWKSFile:  cfg.JWKSFile,
+		Issuer:    cfg.Issuer,
+		Audience:  cfg.Audience,
+		RoleClaim: cfg.RoleClaim,
+	})
+}
+
 func main() {
 	// Force unbuffered output for printing directly to terminal
 	os.Stdout.Sync()
@@ -48,6 +71,46 @@ func main() {
 	fmt.Println("===============================================")
 	os.Stdout.Sync() // Force flush
 
+	// Load backend settings
+	configPath := config.Path()
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		logger.Fatalf("Failed to load configuration: %v", err)
+	}
+	if cfg.ScriptsDir != "" {
+		scripts.SetOverrideDir(cfg.ScriptsDir)
+		logger.Printf("Using script overrides from %s", cfg.ScriptsDir)
+	}
+	if err := handlers.OpenGroundTruth(cfg.GroundTruthFile); err != nil {
+		logger.Fatalf("Failed to open ground truth file: %v", err)
+	}
+	handlers.SetDatasetDir(cfg.DatasetDir)
+	handlers.SetToolsDir(cfg.ToolsDir, cfg.OfflineTools)
+	if cfg.OfflineTools {
+		logger.Printf("Offline tool provisioning from %s", cfg.ToolsDir)
+	}
+	handlers.SetEphemeralImage(cfg.EphemeralImage)
+	handlers.SetAgentBinary(cfg.AgentBinary)
+	if err := handlers.SetTargetAllowlist(cfg.TargetAllowlist); err != nil {
+		logger.Fatalf("Failed to load target allowlist: %v", err)
+	}
+	if len(cfg.TargetAllowlist) == 0 {
+		logger.Printf("Target allowlist is empty, every attack target will be refused")
+	} else if handlers.AllowsAnyTarget() {
+		logger.Printf("Target allowlist covers every address, attacks may target anything")
+	}
+	authenticator, err := newAuthenticator(cfg.Auth)
+	if err != nil {
+		logger.Fatalf("Failed to configure authentication: %v", err)
+	}
+	handlers.SetAuthenticator(authenticator)
+	if authenticator.Disabled() {
+		logger.Printf("Authentication is disabled, the API is open to anyone who can reach it")
+	}
+	if err := handlers.OpenJobStore(cfg.JobStoreFile); err != nil {
+		logger.Fatalf("Failed to open job store: %v", err)
+	}
+
 	// Initialize Kubernetes client
 	logger.Println("Initializing Kubernetes client...")
 	clientset, err := k8s.GetKubeClient()
@@ -56,6 +119,11 @@ func main() {
 	}
 	logger.Println("Kubernetes client initialized successfully!")
 
+	// Check the jobs left running by a previous run against the pods. This
+	// finishes before the server starts, so surviving jobs hold their pods
+	// again before any launch can arrive.
+	handlers.ReconcileJobs(clientset)
+
 	// Set Gin mode to debug for maximum logging
 	gin.SetMode(gin.DebugMode)
 	logger.Println("Gin mode set to DebugMode for verbose logging")
@@ -92,59 +160,127 @@ func main() {
 		os.Stdout.Sync() // Force flush
 	})
 
-	// Add CORS middleware
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	})
+	// Add CORS middleware for the configured origins
+	r.Use(handlers.CORS(cfg.CORSOrigins))
+
+	// Resolve the caller of every request, then gate each route by role
+	r.Use(handlers.Authenticate())
+	viewer := r.Group("", handlers.RequireRole(auth.RoleViewer))
+	operator := r.Group("", handlers.RequireRole(auth.RoleOperator))
+	attacker := r.Group("", handlers.RequireRole(auth.RoleAttacker))
+	admin := r.Group("", handlers.RequireRole(auth.RoleAdmin))
 
 	logger.Println("Routes configuration...")
 	// Routes
-	r.GET("/core-network", handlers.GetCoreNetworkPods(clientset))
-	r.GET("/access-network", handlers.GetAccessNetworkPods(clientset))
-	r.GET("/monitoring", handlers.GetMonitoringPods(clientset))
-	r.POST("/install-ueransim", handlers.InstallUERANSIM())
-	r.POST("/uninstall-ueransim", handlers.UninstallUERANSIM())
-	r.POST("/run-traffic-test", handlers.RunBinningTrafficTest(clientset))
-	r.POST("/stop-traffic-test", handlers.StopBinningTrafficTest(clientset))
-	r.GET("/traffic-test-status", handlers.CheckBinningTrafficTestStatus(clientset))
+	viewer.GET("/auth/whoami", handlers.WhoAmI())
+	viewer.GET("/core-network", handlers.GetCoreNetworkPods(clientset))
+	viewer.GET("/access-network", handlers.GetAccessNetworkPods(clientset))
+	viewer.GET("/monitoring", handlers.GetMonitoringPods(clientset))
+	admin.POST("/install-ueransim", handlers.InstallUERANSIM())
+	admin.POST("/uninstall-ueransim", handlers.UninstallUERANSIM())
+	operator.POST("/run-traffic-test", handlers.RunBinningTrafficTest(clientset))
+	operator.POST("/stop-traffic-test", handlers.StopBinningTrafficTest(clientset))
+	viewer.GET("/traffic-test-status", handlers.CheckBinningTrafficTestStatus(clientset))
+
+	// Generic attack endpoints
+	viewer.GET("/attacks", handlers.ListAttacks())
+	attacker.POST("/attacks/:type/run", handlers.RunAttack(clientset))
+	operator.POST("/attacks/:type/stop", handlers.StopAttack(clientset))
+	viewer.GET("/attacks/:type/status", handlers.CheckAttackStatus(clientset))
+	viewer.GET("/attacks/:type/logs", handlers.AttackLogs(clientset)) // :type holds the job ID
 
 	// DDoS Attack endpoints
-	r.POST("/run-ddos-attack", handlers.RunICMPDDoSAttack(clientset))
-	r.POST("/stop-ddos-attack", handlers.StopDDoSAttack(clientset))
-	r.GET("/ddos-attack-status", handlers.CheckDDoSAttackStatus(clientset))
+	attacker.POST("/run-ddos-attack", handlers.RunICMPDDoSAttack(clientset))
+	operator.POST("/stop-ddos-attack", handlers.StopDDoSAttack(clientset))
+	viewer.GET("/ddos-attack-status", handlers.CheckDDoSAttackStatus(clientset))
 
 	// GTP Encapsulation Attack endpoints
-	r.POST("/run-gtp-encapsulation", handlers.RunGTPEncapsulationAttack(clientset))
-	r.POST("/stop-gtp-encapsulation", handlers.StopGTPEncapsulationAttack(clientset))
-	r.GET("/gtp-encapsulation-status", handlers.CheckGTPEncapsulationAttackStatus(clientset))
+	attacker.POST("/run-gtp-encapsulation", handlers.RunGTPEncapsulationAttack(clientset))
+	operator.POST("/stop-gtp-encapsulation", handlers.StopGTPEncapsulationAttack(clientset))
+	viewer.GET("/gtp-encapsulation-status", handlers.CheckGTPEncapsulationAttackStatus(clientset))
+
+	// Attack agent endpoints
+	attacker.POST("/run-agent-ddos", handlers.RunAgentDDoSAttack(clientset))
+	operator.POST("/stop-agent-ddos", handlers.StopAgentDDoSAttack(clientset))
+	viewer.GET("/agent-ddos-status", handlers.CheckAgentDDoSAttackStatus(clientset))
+	attacker.POST("/run-agent-gtp-encapsulation", handlers.RunAgentGTPEncapsulationAttack(clientset))
+	operator.POST("/stop-agent-gtp-encapsulation", handlers.StopAgentGTPEncapsulationAttack(clientset))
+	viewer.GET("/agent-gtp-encapsulation-status", handlers.CheckAgentGTPEncapsulationAttackStatus(clientset))
 
 	// GTP-U TEID Brute-Force Attack endpoints
-	r.POST("/run-teid-bruteforce", handlers.RunTEIDBruteForceAttack(clientset))
-	r.POST("/stop-teid-bruteforce", handlers.StopTEIDBruteForceAttack(clientset))
-	r.GET("/teid-bruteforce-status", handlers.CheckTEIDBruteForceAttackStatus(clientset))
+	attacker.POST("/run-teid-bruteforce", handlers.RunTEIDBruteForceAttack(clientset))
+	operator.POST("/stop-teid-bruteforce", handlers.StopTEIDBruteForceAttack(clientset))
+	viewer.GET("/teid-bruteforce-status", handlers.CheckTEIDBruteForceAttackStatus(clientset))
 
 	// Intra-UPF UE DoS Attack endpoints
-	r.POST("/run-upf-dos", handlers.RunUPFDosAttack(clientset))
-	r.POST("/stop-upf-dos", handlers.StopUPFDosAttack(clientset))
-	r.GET("/upf-dos-status", handlers.CheckUPFDosAttackStatus(clientset))
+	attacker.POST("/run-upf-dos", handlers.RunUPFDosAttack(clientset))
+	operator.POST("/stop-upf-dos", handlers.StopUPFDosAttack(clientset))
+	viewer.GET("/upf-dos-status", handlers.CheckUPFDosAttackStatus(clientset))
 
 	// Malformed GTP-U Attack endpoints
-	r.POST("/run-malformed-gtpu", handlers.RunMalformedGTPUAttack(clientset))
-	r.POST("/stop-malformed-gtpu", handlers.StopMalformedGTPUAttack(clientset))
-	r.GET("/malformed-gtpu-status", handlers.CheckMalformedGTPUAttackStatus(clientset))
+	attacker.POST("/run-malformed-gtpu", handlers.RunMalformedGTPUAttack(clientset))
+	operator.POST("/stop-malformed-gtpu", handlers.StopMalformedGTPUAttack(clientset))
+	viewer.GET("/malformed-gtpu-status", handlers.CheckMalformedGTPUAttackStatus(clientset))
+
+	// PFCP (N4) Attack endpoints
+	attacker.POST("/run-pfcp-session-deletion", handlers.RunPFCPSessionDeletionAttack(clientset))
+	operator.POST("/stop-pfcp-session-deletion", handlers.StopPFCPSessionDeletionAttack(clientset))
+	viewer.GET("/pfcp-session-deletion-status", handlers.CheckPFCPSessionDeletionAttackStatus(clientset))
+	attacker.POST("/run-pfcp-session-modification", handlers.RunPFCPSessionModificationAttack(clientset))
+	operator.POST("/stop-pfcp-session-modification", handlers.StopPFCPSessionModificationAttack(clientset))
+	viewer.GET("/pfcp-session-modification-status", handlers.CheckPFCPSessionModificationAttackStatus(clientset))
+	attacker.POST("/run-pfcp-establishment-flood", handlers.RunPFCPEstablishmentFloodAttack(clientset))
+	operator.POST("/stop-pfcp-establishment-flood", handlers.StopPFCPEstablishmentFloodAttack(clientset))
+	viewer.GET("/pfcp-establishment-flood-status", handlers.CheckPFCPEstablishmentFloodAttackStatus(clientset))
+
+	// NGAP/SCTP Attack endpoints
+	attacker.POST("/run-sctp-init-flood", handlers.RunSCTPInitFloodAttack(clientset))
+	operator.POST("/stop-sctp-init-flood", handlers.StopSCTPInitFloodAttack(clientset))
+	viewer.GET("/sctp-init-flood-status", handlers.CheckSCTPInitFloodAttackStatus(clientset))
+	attacker.POST("/run-ngap-malformed-setup", handlers.RunNGAPMalformedSetupAttack(clientset))
+	operator.POST("/stop-ngap-malformed-setup", handlers.StopNGAPMalformedSetupAttack(clientset))
+	viewer.GET("/ngap-malformed-setup-status", handlers.CheckNGAPMalformedSetupAttackStatus(clientset))
+	attacker.POST("/run-ngap-ue-context-release", handlers.RunNGAPUEContextReleaseAttack(clientset))
+	operator.POST("/stop-ngap-ue-context-release", handlers.StopNGAPUEContextReleaseAttack(clientset))
+	viewer.GET("/ngap-ue-context-release-status", handlers.CheckNGAPUEContextReleaseAttackStatus(clientset))
+
+	// Registration storm endpoints, addressed by UERANSIM release
+	attacker.POST("/registration-storm/run", handlers.RunRegistrationStorm(clientset))
+	operator.POST("/registration-storm/stop", handlers.StopRegistrationStorm(clientset))
+	viewer.GET("/registration-storm/status", handlers.CheckRegistrationStormStatus(clientset))
 
 	// Trace collector routes
-	r.POST("/traces/start", handlers.StartTraceCollector(clientset))
-	r.POST("/traces/stop", handlers.StopTraceCollector())
-	r.GET("/traces/status", handlers.GetTraceCollectorStatus())
-	r.PUT("/traces/configure", handlers.ConfigureTraceCollector())
+	operator.POST("/traces/start", handlers.StartTraceCollector(clientset))
+	operator.POST("/traces/stop", handlers.StopTraceCollector())
+	viewer.GET("/traces/status", handlers.GetTraceCollectorStatus())
+	admin.PUT("/traces/configure", handlers.ConfigureTraceCollector())
+
+	// Preflight routes
+	viewer.GET("/preflight", handlers.Preflight(clientset))
+	viewer.POST("/preflight", handlers.Preflight(clientset))
+
+	// Job store routes
+	viewer.GET("/jobs", handlers.ListJobs())
+	viewer.GET("/jobs/:id", handlers.GetJob())
+	viewer.GET("/jobs/:id/logs", handlers.AttackLogs(clientset))
+	viewer.GET("/leases", handlers.ListPodLeases())
+
+	// Ground truth routes
+	viewer.GET("/ground-truth", handlers.GetGroundTruth())
+
+	// Dataset export routes
+	operator.POST("/datasets/export", handlers.StartDatasetExport())
+	viewer.GET("/datasets/exports", handlers.ListDatasetExports())
+	viewer.GET("/datasets/exports/:id", handlers.GetDatasetExport())
+
+	// Scenario routes
+	attacker.POST("/scenarios", handlers.StartScenario(clientset))
+	viewer.GET("/scenarios", handlers.ListScenarios())
+	viewer.GET("/scenarios/:id", handlers.GetScenario())
+	operator.POST("/scenarios/:id/stop", handlers.StopScenario())
+
+	// Emergency stop route
+	operator.POST("/emergency-stop", handlers.EmergencyStop(clientset))
 
 	// URL List
 	// http://localhost:8081/core-network
@@ -155,6 +291,11 @@ func main() {
 	// http://localhost:8081/run-traffic-test
 	// http://localhost:8081/stop-traffic-test
 	// http://localhost:8081/traffic-test-status
+	// http://localhost:8081/attacks
+	// http://localhost:8081/attacks/{type}/run
+	// http://localhost:8081/attacks/{type}/stop
+	// http://localhost:8081/attacks/{type}/status
+	// http://localhost:8081/attacks/{id}/logs
 	// http://localhost:8081/run-ddos-attack
 	// http://localhost:8081/stop-ddos-attack
 	// http://localhost:8081/ddos-attack-status
@@ -170,10 +311,42 @@ func main() {
 	// http://localhost:8081/run-malformed-gtpu
 	// http://localhost:8081/stop-malformed-gtpu
 	// http://localhost:8081/malformed-gtpu-status
+	// http://localhost:8081/run-pfcp-session-deletion
+	// http://localhost:8081/stop-pfcp-session-deletion
+	// http://localhost:8081/pfcp-session-deletion-status
+	// http://localhost:8081/run-pfcp-session-modification
+	// http://localhost:8081/stop-pfcp-session-modification
+	// http://localhost:8081/pfcp-session-modification-status
+	// http://localhost:8081/run-pfcp-establishment-flood
+	// http://localhost:8081/stop-pfcp-establishment-flood
+	// http://localhost:8081/pfcp-establishment-flood-status
+	// http://localhost:8081/run-sctp-init-flood
+	// http://localhost:8081/stop-sctp-init-flood
+	// http://localhost:8081/sctp-init-flood-status
+	// http://localhost:8081/run-ngap-malformed-setup
+	// http://localhost:8081/stop-ngap-malformed-setup
+	// http://localhost:8081/ngap-malformed-setup-status
+	// http://localhost:8081/run-ngap-ue-context-release
+	// http://localhost:8081/stop-ngap-ue-context-release
+	// http://localhost:8081/ngap-ue-context-release-status
+	// http://localhost:8081/registration-storm/run
+	// http://localhost:8081/registration-storm/stop
+	// http://localhost:8081/registration-storm/status
 	// http://localhost:8081/traces/start
 	// http://localhost:8081/traces/stop
 	// http://localhost:8081/traces/status
 	// http://localhost:8081/traces/configure
+	// http://localhost:8081/preflight
+	// http://localhost:8081/jobs
+	// http://localhost:8081/jobs/{id}
+	// http://localhost:8081/jobs/{id}/logs
+	// http://localhost:8081/ground-truth
+	// http://localhost:8081/datasets/export
+	// http://localhost:8081/datasets/exports
+	// http://localhost:8081/datasets/exports/{id}
+	// http://localhost:8081/scenarios
+	// http://localhost:8081/scenarios/{id}
+	// http://localhost:8081/scenarios/{id}/stop
 
 	logger.Println("Starting server with forced terminal output...")
 	fmt.Println("Server ready to accept connections")
